@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Aggregate struct {
+	Function string
+	Field    *FieldMetaData
+}
+
+func aggregateRows(table string, d *DbExplorer, w http.ResponseWriter, query url.Values) error {
+	tableData := d.schema.Data[table]
+
+	groupFields := make([]FieldMetaData, 0)
+	if group := query.Get("group"); group != "" {
+		for _, name := range strings.Split(group, ",") {
+			datum := findField(tableData, name)
+			if datum == nil {
+				writeError(w, fmt.Sprintf("unknown field %s", name), http.StatusBadRequest)
+				return nil
+			}
+			groupFields = append(groupFields, *datum)
+		}
+	}
+
+	aggregates, err := parseAggregates(tableData, query.Get("agg"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	filters, err := parseColumnFilters(tableData, query, "group", "agg", "decimals", "bigints")
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	expressions := make([]string, 0, len(groupFields)+len(aggregates))
+	resultFields := make([]FieldMetaData, 0, len(groupFields)+len(aggregates))
+	for _, datum := range groupFields {
+		expressions = append(expressions, fmt.Sprintf("`%s`", escapeIdentifier(datum.Field)))
+		resultFields = append(resultFields, datum)
+	}
+	for _, aggregate := range aggregates {
+		expressions = append(expressions, aggregate.expression())
+		resultFields = append(resultFields, aggregate.resultField())
+	}
+
+	where, args := buildWhereClause(filters)
+	var groupBy, orderBy string
+	if len(groupFields) > 0 {
+		groupBy = " GROUP BY `" + getAndFormatFieldNamesForQuery(groupFields) + "`"
+		orderBy = " ORDER BY `" + getAndFormatFieldNamesForQuery(groupFields) + "`"
+	}
+
+	fullQuery := fmt.Sprintf(d.AggregateQuery, strings.Join(expressions, ", "), escapeIdentifier(table), where, groupBy, orderBy)
+	rs, err := d.DB.Query(fullQuery, args...)
+	if err != nil {
+		return err
+	}
+	result, err := processRs(rs, resultFields)
+	rs.Close()
+	if err != nil {
+		return err
+	}
+
+	format.apply(result)
+	writeRecords(w, result, nil)
+	return nil
+}
+
+func distinctValues(table string, column string, d *DbExplorer, w http.ResponseWriter, query url.Values) error {
+	datum := findField(d.schema.Data[table], column)
+	if datum == nil {
+		writeError(w, fmt.Sprintf("unknown field %s", column), http.StatusBadRequest)
+		return nil
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	filters, err := parseColumnFilters(d.schema.Data[table], query, "limit", "decimals", "bigints")
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	limit, err := parseLimitOrOffset(query, "limit", 50)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	where, args := buildWhereClause(filters)
+	args = append(args, limit)
+
+	column = escapeIdentifier(datum.Field)
+	fullQuery := fmt.Sprintf(d.DistinctQuery, column, escapeIdentifier(table), where, column, column)
+	rs, err := d.DB.Query(fullQuery, args...)
+	if err != nil {
+		return err
+	}
+	result, err := processRs(rs, []FieldMetaData{
+		{Field: "value", Type: datum.Type, JSON: datum.JSON},
+		{Field: "count", Type: "int"},
+	})
+	rs.Close()
+	if err != nil {
+		return err
+	}
+
+	format.apply(result)
+	writeRecords(w, result, nil)
+	return nil
+}
+
+func parseAggregates(tableData []FieldMetaData, agg string) ([]Aggregate, error) {
+	if agg == "" {
+		return nil, fmt.Errorf("agg is required")
+	}
+
+	aggregates := make([]Aggregate, 0)
+	for _, expression := range strings.Split(agg, ",") {
+		function, argument, found := strings.Cut(strings.TrimSuffix(expression, ")"), "(")
+		if !found || !strings.HasSuffix(expression, ")") {
+			return nil, fmt.Errorf("invalid aggregate %s", expression)
+		}
+
+		function = strings.ToLower(function)
+		switch function {
+		case "count", "sum", "avg", "min", "max":
+		default:
+			return nil, fmt.Errorf("unknown aggregate function %s", function)
+		}
+
+		aggregate := Aggregate{Function: function}
+		if argument == "*" {
+			if function != "count" {
+				return nil, fmt.Errorf("invalid aggregate %s", expression)
+			}
+		} else {
+			aggregate.Field = findField(tableData, argument)
+			if aggregate.Field == nil {
+				return nil, fmt.Errorf("unknown field %s", argument)
+			}
+			kind := fieldKind(*aggregate.Field)
+			if (function == "sum" || function == "avg") && kind != "int" && kind != "uint" && kind != "float" && kind != "decimal" {
+				return nil, fmt.Errorf("aggregate %s needs a numeric field %s", function, argument)
+			}
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+	return aggregates, nil
+}
+
+func (a Aggregate) name() string {
+	if a.Field == nil {
+		return a.Function + "(*)"
+	}
+	return fmt.Sprintf("%s(%s)", a.Function, a.Field.Field)
+}
+
+func (a Aggregate) expression() string {
+	if a.Field == nil {
+		return strings.ToUpper(a.Function) + "(*)"
+	}
+	return fmt.Sprintf("%s(`%s`)", strings.ToUpper(a.Function), escapeIdentifier(a.Field.Field))
+}
+
+// SUM of integers is a DECIMAL in MySQL, it is read as bigint unless
+// the sum of a bigint column could overflow it
+func (a Aggregate) resultField() FieldMetaData {
+	switch {
+	case a.Function == "count":
+		return FieldMetaData{Field: a.name(), Type: "int"}
+	case a.Function == "avg":
+		return FieldMetaData{Field: a.name(), Type: "float"}
+	case a.Function == "sum" && isIntegerType(a.Field.Type) && integerBits(a.Field.Type) < 64:
+		return FieldMetaData{Field: a.name(), Type: "bigint"}
+	case a.Function == "sum" && isIntegerType(a.Field.Type):
+		return FieldMetaData{Field: a.name(), Type: "decimal"}
+	case a.Function == "sum" && typeKind(a.Field.Type) == "float":
+		return FieldMetaData{Field: a.name(), Type: "double"}
+	case a.Function == "sum":
+		return FieldMetaData{Field: a.name(), Type: "decimal"}
+	default:
+		return FieldMetaData{Field: a.name(), Type: a.Field.Type, JSON: a.Field.JSON}
+	}
+}
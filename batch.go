@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+type BatchOperation struct {
+	Op    string                 `json:"op"`
+	Table string                 `json:"table"`
+	Id    interface{}            `json:"id"`
+	Body  map[string]interface{} `json:"body"`
+}
+
+// BatchError rejects the operation with the given index, nothing of the batch is written
+type BatchError struct {
+	Operation int
+	Message   string
+	Fields    []FieldError
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Operation, e.Message)
+}
+
+func runBatch(d *DbExplorer, w http.ResponseWriter, body []byte, format Format) error {
+	operations := make([]BatchOperation, 0)
+	if err := decodeBody(body, &operations); err != nil {
+		writeError(w, "invalid batch", http.StatusBadRequest)
+		return nil
+	}
+	if len(operations) == 0 {
+		writeError(w, "no operations in batch", http.StatusBadRequest)
+		return nil
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	results := make([]map[string]interface{}, 0, len(operations))
+	for i, operation := range operations {
+		result, err := runOperation(tx, d, operation, results)
+		var batchError BatchError
+		if errors.As(err, &batchError) {
+			batchError.Operation = i
+			writeBatchError(w, batchError)
+			return nil
+		}
+		if err != nil {
+			// errors caused by the data of an operation point at it
+			apiError := mapError(err, operation.Table, d)
+			if apiError.Status < http.StatusInternalServerError {
+				apiError.Operation = &i
+				writeAPIError(w, apiError)
+				return nil
+			}
+			return err
+		}
+		results = append(results, result)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	format.apply(results)
+	writeResponse(w, struct {
+		Results []map[string]interface{} `json:"results"`
+	}{results})
+	return nil
+}
+
+func runOperation(q Querier, d *DbExplorer, operation BatchOperation, results []map[string]interface{}) (map[string]interface{}, error) {
+	table := operation.Table
+	if !slices.Contains(d.schema.TableNames, table) {
+		return nil, BatchError{Message: fmt.Sprintf("unknown table %s", table)}
+	}
+
+	resolved, err := resolveReferences(operation.Body, results, d.ReferenceRegexp)
+	if err != nil {
+		return nil, err
+	}
+	input, _ := resolved.(map[string]interface{})
+	if input == nil {
+		input = make(map[string]interface{})
+	}
+
+	if operation.Op == "create" {
+		if fieldErrors := validateInput(d.schema.Data[table], input, false); len(fieldErrors) > 0 {
+			return nil, BatchError{Message: joinFieldErrors(fieldErrors), Fields: fieldErrors}
+		}
+		return insertRecord(q, table, d, input)
+	}
+
+	id, err := parseBatchId(table, d, operation.Id, results)
+	if err != nil {
+		return nil, err
+	}
+	if operation.Op == "update" || operation.Op == "delete" {
+		record, err := lockRow(q, table, d, id)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, BatchError{Message: "record not found"}
+		}
+	}
+
+	switch operation.Op {
+	case "update":
+		if fieldErrors := validateUpdate(table, d, input); len(fieldErrors) > 0 {
+			return nil, BatchError{Message: joinFieldErrors(fieldErrors), Fields: fieldErrors}
+		}
+		fieldNames, fieldValues, err := createDataForQuery(d.schema.Data[table], input)
+		if err != nil {
+			return nil, err
+		}
+		if len(fieldNames) == 0 {
+			return nil, BatchError{Message: "no fields to update"}
+		}
+		updated, err := updateRecord(q, table, d, id, fieldNames, fieldValues)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"updated": updated}, nil
+	case "delete":
+		deleted, err := deleteRecord(q, table, d, id)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"deleted": deleted}, nil
+	}
+	return nil, BatchError{Message: fmt.Sprintf("unknown op %s", operation.Op)}
+}
+
+// an id is a single value or a list of values for a composite primary key
+func parseBatchId(table string, d *DbExplorer, id interface{}, results []map[string]interface{}) ([]interface{}, error) {
+	resolved, err := resolveReferences(id, results, d.ReferenceRegexp)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := resolved.([]interface{})
+	if !ok {
+		values = []interface{}{resolved}
+	}
+
+	parts := make([]string, len(values))
+	for i, value := range values {
+		switch typed := value.(type) {
+		case json.Number:
+			parts[i] = typed.String()
+		case string:
+			parts[i] = typed
+		default:
+			return nil, BatchError{Message: "invalid id"}
+		}
+	}
+
+	key, err := parseKeyValues(table, d, parts)
+	if err != nil {
+		return nil, BatchError{Message: err.Error()}
+	}
+	return key, nil
+}
+
+// resolveReferences replaces objects like {"$ref": "0.id"} with the field of an earlier
+// result, strings are always taken as they are. The value goes through json,
+// so it looks like one sent in the request body.
+func resolveReferences(value interface{}, results []map[string]interface{}, reference *regexp.Regexp) (interface{}, error) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if target, isReference := typed["$ref"]; isReference && len(typed) == 1 {
+			return resolveReference(target, results, reference)
+		}
+		for key, item := range typed {
+			resolved, err := resolveReferences(item, results, reference)
+			if err != nil {
+				return nil, err
+			}
+			typed[key] = resolved
+		}
+	case []interface{}:
+		for i, item := range typed {
+			resolved, err := resolveReferences(item, results, reference)
+			if err != nil {
+				return nil, err
+			}
+			typed[i] = resolved
+		}
+	}
+	return value, nil
+}
+
+func resolveReference(target interface{}, results []map[string]interface{}, reference *regexp.Regexp) (interface{}, error) {
+	text, _ := target.(string)
+	match := reference.FindStringSubmatch(text)
+	if match == nil {
+		return nil, BatchError{Message: fmt.Sprintf("invalid reference %v", target)}
+	}
+	index, err := strconv.Atoi(match[1])
+	if err != nil || index >= len(results) {
+		return nil, BatchError{Message: fmt.Sprintf("invalid reference %s", text)}
+	}
+	field, exists := results[index][match[2]]
+	if !exists {
+		return nil, BatchError{Message: fmt.Sprintf("invalid reference %s", text)}
+	}
+	marshal, err := json.Marshal(field)
+	if err != nil {
+		return nil, err
+	}
+	var resolved interface{}
+	err = decodeBody(marshal, &resolved)
+	return resolved, err
+}
+
+func updateRecord(q Querier, table string, d *DbExplorer, id []interface{}, fieldNames []string, fieldValues []interface{}) (int64, error) {
+	query := fmt.Sprintf(d.UpdateQuery,
+		escapeIdentifier(table),
+		withVersionBump(table, d, buildAssignments(fieldNames)),
+		buildPrimaryKeyCondition(getPrimaryKey(table, d)))
+	res, err := q.Exec(query, append(fieldValues, id...)...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func deleteRecord(q Querier, table string, d *DbExplorer, id []interface{}) (int64, error) {
+	query := fmt.Sprintf(d.DeleteQuery, escapeIdentifier(table), buildPrimaryKeyCondition(getPrimaryKey(table, d)))
+	res, err := q.Exec(query, id...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func writeBatchError(w http.ResponseWriter, batchError BatchError) {
+	code := "bad_request"
+	if len(batchError.Fields) > 0 {
+		code = "invalid_fields"
+	}
+	writeAPIError(w, &APIError{
+		Status:    http.StatusBadRequest,
+		Code:      code,
+		Message:   batchError.Error(),
+		Operation: &batchError.Operation,
+		Fields:    batchError.Fields,
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func createRows(table string, d *DbExplorer, w http.ResponseWriter, body []byte, format Format) error {
+	inputs := make([]map[string]interface{}, 0)
+	err := decodeBody(body, &inputs)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		writeError(w, "no records to insert", http.StatusBadRequest)
+		return nil
+	}
+
+	fieldErrors := make([]FieldError, 0)
+	for i, input := range inputs {
+		for _, fieldError := range validateInput(d.schema.Data[table], input, false) {
+			row := i
+			fieldError.Row = &row
+			fieldErrors = append(fieldErrors, fieldError)
+		}
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return nil
+	}
+
+	rows := make([]map[string]interface{}, len(inputs))
+	present := make(map[string]bool)
+	for i, input := range inputs {
+		fillMissingFields(d.schema.Data[table], input)
+		names, values, err := createDataForQuery(d.schema.Data[table], input)
+		if err != nil {
+			return err
+		}
+		rows[i] = make(map[string]interface{}, len(names))
+		for j, name := range names {
+			rows[i][name] = values[j]
+			present[name] = true
+		}
+	}
+	columns := make([]string, 0, len(present))
+	for _, datum := range d.schema.Data[table] {
+		if present[datum.Field] {
+			columns = append(columns, datum.Field)
+		}
+	}
+
+	chunkSize := d.BulkChunkSize
+	if len(columns) > 0 && d.MaxPlaceholders/len(columns) < chunkSize {
+		chunkSize = d.MaxPlaceholders / len(columns)
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	primaryKey := getPrimaryKey(table, d)
+	ids := make([]map[string]interface{}, 0, len(rows))
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		tuples := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			placeholders := make([]string, len(columns))
+			for i, column := range columns {
+				value, exists := row[column]
+				if !exists {
+					placeholders[i] = "DEFAULT"
+					continue
+				}
+				placeholders[i] = "?"
+				args = append(args, value)
+			}
+			tuples = append(tuples, "("+strings.Join(placeholders, ", ")+")")
+		}
+
+		query := fmt.Sprintf(d.BulkInsertQuery,
+			escapeIdentifier(table),
+			escapeIdentifiers(columns),
+			strings.Join(tuples, ", "),
+			getAndFormatFieldNamesForQuery(primaryKey))
+		rs, err := tx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		result, err := processRs(rs, primaryKey)
+		rs.Close()
+		if err != nil {
+			return err
+		}
+		ids = append(ids, result...)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	format.apply(ids)
+	writeResponse(w, struct {
+		Ids []map[string]interface{} `json:"ids"`
+	}{ids})
+	return nil
+}
+
+func updateRows(table string, d *DbExplorer, w http.ResponseWriter, body []byte, query url.Values) error {
+	input := make(map[string]interface{})
+	err := decodeBody(body, &input)
+	if err != nil {
+		return err
+	}
+
+	filters, dryRun, err := parseBulkParams(d.schema.Data[table], query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	if fieldErrors := validateUpdate(table, d, input); len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return nil
+	}
+	fieldNames, fieldValues, err := createDataForQuery(d.schema.Data[table], input)
+	if err != nil {
+		return err
+	}
+	if len(fieldNames) == 0 {
+		writeError(w, "no fields to update", http.StatusBadRequest)
+		return nil
+	}
+
+	where, args := buildWhereClause(filters)
+	fullQuery := fmt.Sprintf(d.BulkUpdateQuery, escapeIdentifier(table), withVersionBump(table, d, buildAssignments(fieldNames)), where)
+	affected, err := execFiltered(table, d, where, args, dryRun, fullQuery, append(fieldValues, args...)...)
+	if errors.As(err, &AffectedRowsError{}) {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, struct {
+		Updated int64 `json:"updated"`
+		DryRun  bool  `json:"dry_run,omitempty"`
+	}{affected, dryRun})
+	return nil
+}
+
+func deleteRows(table string, d *DbExplorer, w http.ResponseWriter, query url.Values) error {
+	filters, dryRun, err := parseBulkParams(d.schema.Data[table], query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	where, args := buildWhereClause(filters)
+	fullQuery := fmt.Sprintf(d.BulkDeleteQuery, escapeIdentifier(table), where)
+	affected, err := execFiltered(table, d, where, args, dryRun, fullQuery, args...)
+	if errors.As(err, &AffectedRowsError{}) {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, struct {
+		Deleted int64 `json:"deleted"`
+		DryRun  bool  `json:"dry_run,omitempty"`
+	}{affected, dryRun})
+	return nil
+}
+
+// a filter is mandatory so a request without one can not wipe the whole table
+func parseBulkParams(tableData []FieldMetaData, query url.Values) ([]Filter, bool, error) {
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid dry_run %s", value)
+		}
+		dryRun = parsed
+	}
+
+	filters, err := parseColumnFilters(tableData, query, "dry_run")
+	if err != nil {
+		return nil, false, err
+	}
+	if len(filters) == 0 {
+		return nil, false, fmt.Errorf("filter is required")
+	}
+	return filters, dryRun, nil
+}
+
+type AffectedRowsError struct {
+	Matched int64
+	Max     int64
+}
+
+func (e AffectedRowsError) Error() string {
+	return fmt.Sprintf("filter matches %d rows, at most %d can be changed at once", e.Matched, e.Max)
+}
+
+// execFiltered counts and locks the matching rows before running the
+// statement in the same transaction, so MaxAffectedRows can not be exceeded
+// by rows inserted in between
+func execFiltered(table string, d *DbExplorer, where string, whereArgs []interface{}, dryRun bool, query string, args ...interface{}) (int64, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	countQuery := fmt.Sprintf(d.CountQuery, escapeIdentifier(table), where)
+	if !dryRun {
+		countQuery += " FOR UPDATE"
+	}
+	var matched int64
+	err = tx.QueryRow(countQuery, whereArgs...).Scan(&matched)
+	if err != nil {
+		return 0, err
+	}
+	if matched > d.MaxAffectedRows {
+		return 0, AffectedRowsError{matched, d.MaxAffectedRows}
+	}
+	if dryRun {
+		return matched, nil
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return affected, tx.Commit()
+}
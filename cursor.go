@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func encodeCursor(record map[string]interface{}, order []OrderBy) (string, error) {
+	values := make([]interface{}, len(order))
+	for i, orderBy := range order {
+		values[i] = record[orderBy.Field.Field]
+	}
+	marshal, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(marshal), nil
+}
+
+func decodeCursor(cursor string, order []OrderBy) ([]interface{}, error) {
+	invalidCursor := fmt.Errorf("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidCursor
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	values := make([]interface{}, 0)
+	if err := decoder.Decode(&values); err != nil || len(values) != len(order) {
+		return nil, invalidCursor
+	}
+
+	for i, value := range values {
+		switch typed := value.(type) {
+		case json.Number:
+			values[i], err = convertQueryValue(typed.String(), order[i].Field)
+		case string:
+			values[i], err = convertQueryValue(typed, order[i].Field)
+		case map[string]interface{}, []interface{}:
+			values[i], err = convertInput(typed, order[i].Field)
+		}
+		if err != nil {
+			return nil, invalidCursor
+		}
+	}
+	return values, nil
+}
+
+// rows after the cursor are those greater on the first sort column,
+// or equal on it and greater on the next one, and so on
+func buildCursorCondition(order []OrderBy, values []interface{}) (string, []interface{}) {
+	alternatives := make([]string, 0, len(order))
+	args := make([]interface{}, 0)
+	for i := range order {
+		terms := make([]string, 0, i+1)
+		termArgs := make([]interface{}, 0, i+1)
+		for j := 0; j < i; j++ {
+			column := fmt.Sprintf("`%s`", escapeIdentifier(order[j].Field.Field))
+			if values[j] == nil {
+				terms = append(terms, column+" IS NULL")
+			} else {
+				terms = append(terms, column+" = ?")
+				termArgs = append(termArgs, values[j])
+			}
+		}
+
+		after, afterArgs := cursorAfterTerm(order[i], values[i])
+		terms = append(terms, after)
+		termArgs = append(termArgs, afterArgs...)
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		args = append(args, termArgs...)
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func cursorAfterTerm(orderBy OrderBy, value interface{}) (string, []interface{}) {
+	column := fmt.Sprintf("`%s`", escapeIdentifier(orderBy.Field.Field))
+	nullsFirst := orderBy.Nulls == "first" || (orderBy.Nulls == "" && !orderBy.Descending)
+	if value == nil {
+		if nullsFirst {
+			return column + " IS NOT NULL", nil
+		}
+		return "FALSE", nil
+	}
+
+	comparison := " > ?"
+	if orderBy.Descending {
+		comparison = " < ?"
+	}
+	if nullsFirst {
+		return column + comparison, []interface{}{value}
+	}
+	return "(" + column + comparison + " OR " + column + " IS NULL)", []interface{}{value}
+}
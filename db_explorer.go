@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"regexp"
-	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // тут вы пишете код
@@ -25,230 +29,288 @@ type FieldMetaData struct {
 	Extra      sql.NullString
 	Privileges sql.NullString
 	Comment    sql.NullString
+	// JSON marks the text columns MariaDB uses for json, Type keeps the reported type
+	JSON bool
+}
+
+// Querier is implemented by *sql.DB and *sql.Tx,
+// so the same writes can run alone or inside a batch
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
 }
 
 type DbExplorer struct {
-	DB                *sql.DB
-	TableNames        []string
-	Data              map[string][]FieldMetaData
-	LimitOffsetRegexp *regexp.Regexp
-	ByIdRegexp        *regexp.Regexp
-	GetQuery          string
-	LimitOffsetQuery  string
-	GetByIdQuery      string
-	InsertQuery       string
-	UpdateQuery       string
-	DeleteQuery       string
-}
-
-func (d *DbExplorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-	queryParams := r.URL.RawQuery
-	method := r.Method
-	if path == "/" && method == http.MethodGet {
-		writeTables(w, d.TableNames)
-		return
-	}
+	DB *sql.DB
+	// schema is only set on the copy serving a request, to the snapshot ServeHTTP
+	// took from currentSchema. A reload replaces currentSchema without waiting
+	// for running requests, reloadMutex keeps reloads from overlapping.
+	schema          *Schema
+	currentSchema   *atomic.Pointer[Schema]
+	reloadMutex     *sync.Mutex
+	Routes          []Route
+	ReferenceRegexp *regexp.Regexp
+	// ETags are computed from the version column of a table if one is set
+	VersionColumns      map[string]string
+	GetQuery            string
+	LimitOffsetQuery    string
+	CursorQuery         string
+	CountQuery          string
+	EstimatedCountQuery string
+	EmbedQuery          string
+	SearchQuery         string
+	AggregateQuery      string
+	DistinctQuery       string
+	GetByIdQuery        string
+	InsertQuery         string
+	BulkInsertQuery     string
+	UpsertQuery         string
+	UpdateQuery         string
+	DeleteQuery         string
+	BulkUpdateQuery     string
+	BulkDeleteQuery     string
+	// rows of a bulk insert are sent in chunks of at most BulkChunkSize rows
+	// and MaxPlaceholders bound values, the limit of the MySQL protocol
+	BulkChunkSize   int
+	MaxPlaceholders int
+	// updates and deletes by filter are refused when more rows match
+	MaxAffectedRows int64
+}
 
-	tableName := extractFuncName(path)
-	afterTable := path[len(tableName)+1:]
-	if !slices.Contains(d.TableNames, tableName) {
-		writeError(w, "unknown table", http.StatusNotFound)
-		return
+func deleteRow(table string, d *DbExplorer, w http.ResponseWriter, restOfPath string, ifMatch string) error {
+	idForDelete, err := parsePrimaryKey(table, d, restOfPath)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
 	}
 
-	if method == http.MethodGet && afterTable == "" && queryParams == "" {
-		err := getRows(tableName, d, w)
-		if err != nil {
-			writeError(w, err.Error(), http.StatusInternalServerError)
-		}
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
 	}
-
-	if method == http.MethodGet && d.LimitOffsetRegexp.MatchString(queryParams) {
-		err := getWithLimitAndOffset(tableName, queryParams, d, w)
-		if err != nil {
-			writeError(w, err.Error(), http.StatusInternalServerError)
-		}
+	defer tx.Rollback()
+	if ok, err := checkRow(tx, table, d, w, idForDelete, ifMatch); !ok || err != nil {
+		return err
 	}
 
-	if method == http.MethodGet && d.ByIdRegexp.MatchString(afterTable) {
-		err := getById(tableName, d, w, afterTable)
-		if err != nil {
-			writeError(w, err.Error(), http.StatusInternalServerError)
-		}
+	deleted, err := deleteRecord(tx, table, d, idForDelete)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
 	}
 
-	if method == http.MethodPut {
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			writeError(w, err.Error(), http.StatusInternalServerError)
-		}
-		r.Body.Close()
-		createRow(tableName, d, w, body)
+	writeResponse(w, struct {
+		Deleted int64 `json:"deleted"`
+	}{deleted})
+	return nil
+}
+
+// updated counts changed rows, a row that already has the sent values gives 0
+func updateRow(table string, d *DbExplorer, w http.ResponseWriter, body []byte, restOfPath string, ifMatch string) error {
+	input := make(map[string]interface{})
+	err := decodeBody(body, &input)
+	if err != nil {
+		return err
 	}
 
-	if method == http.MethodPost && d.ByIdRegexp.MatchString(afterTable) {
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			writeError(w, err.Error(), http.StatusInternalServerError)
-		}
-		r.Body.Close()
-		updateRow(tableName, d, w, body, afterTable)
+	idForUpdate, err := parsePrimaryKey(table, d, restOfPath)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
 	}
 
-	if method == http.MethodDelete && d.ByIdRegexp.MatchString(afterTable) {
-		deleteRow(tableName, d, w, afterTable)
+	if fieldErrors := validateUpdate(table, d, input); len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return nil
 	}
-}
 
-func deleteRow(table string, d *DbExplorer, w http.ResponseWriter, restOfPath string) error {
-	idForDelete, err := strconv.Atoi(restOfPath[1:])
+	fieldNames, fieldValues, err := createDataForQuery(d.schema.Data[table], input)
 	if err != nil {
 		return err
 	}
+	if len(fieldNames) == 0 {
+		writeError(w, "no fields to update", http.StatusBadRequest)
+		return nil
+	}
 
-	idFieldName := getPrimaryKey(table, d)
-
-	query := fmt.Sprintf(d.DeleteQuery, table, idFieldName)
-	_, err = d.DB.Query(query, idForDelete)
+	tx, err := d.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+	if ok, err := checkRow(tx, table, d, w, idForUpdate, ifMatch); !ok || err != nil {
+		return err
+	}
 
-	res, err := d.DB.Query("SELECT ROW_COUNT()")
+	updated, err := updateRecord(tx, table, d, idForUpdate, fieldNames, fieldValues)
 	if err != nil {
 		return err
 	}
-	res.Next()
-	var deleted int
-	err = res.Scan(&deleted)
+	err = tx.Commit()
 	if err != nil {
 		return err
 	}
-	res.Close()
 
 	writeResponse(w, struct {
-		Updated int `json:"deleted"`
-	}{deleted})
+		Updated int64 `json:"updated"`
+	}{updated})
 	return nil
 }
 
-func updateRow(table string, d *DbExplorer, w http.ResponseWriter, body []byte, restOfPath string) error {
-	input := make(map[string]interface{})
-	err := json.Unmarshal(body, &input)
+// checkRow locks the row before a write and answers 404 when it is missing
+// or 412 when it does not match the If-Match header
+func checkRow(q Querier, table string, d *DbExplorer, w http.ResponseWriter, id []interface{}, ifMatch string) (bool, error) {
+	record, err := lockRow(q, table, d, id)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if record == nil {
+		writeError(w, "record not found", http.StatusNotFound)
+		return false, nil
+	}
+	if ifMatch == "" {
+		return true, nil
 	}
 
-	idForUpdate, err := strconv.Atoi(restOfPath[1:])
+	matches, err := matchesETag(record, etagFields(table, d), ifMatch)
 	if err != nil {
-		return err
+		return false, err
 	}
+	if !matches {
+		writeError(w, "precondition failed", http.StatusPreconditionFailed)
+	}
+	return matches, nil
+}
 
-	fieldNames, fieldValues, idFieldName := createDataForQuery(d.Data[table], input)
-
-	updateExpression := make([]string, len(fieldNames))
-	for i := range fieldNames {
-		updateExpression[i] = fmt.Sprintf("%s = %s", fieldNames[i], fieldValues[i])
+// lockRow reads the fields of the row ETag and locks it until the end of the transaction,
+// a missing row gives nil
+func lockRow(q Querier, table string, d *DbExplorer, id []interface{}) (map[string]interface{}, error) {
+	fields := etagFields(table, d)
+	query := fmt.Sprintf(d.GetByIdQuery,
+		getAndFormatFieldNamesForQuery(fields),
+		escapeIdentifier(table),
+		buildPrimaryKeyCondition(getPrimaryKey(table, d))) + " FOR UPDATE"
+	rs, err := q.Query(query, id...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := processRs(rs, fields)
+	rs.Close()
+	if err != nil || len(result) == 0 {
+		return nil, err
 	}
+	return result[0], nil
+}
 
-	query := fmt.Sprintf(d.UpdateQuery,
-		table,
-		strings.Join(updateExpression, ", "),
-		idFieldName)
+func createRow(table string, d *DbExplorer, w http.ResponseWriter, body []byte, format Format) error {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		return createRows(table, d, w, body, format)
+	}
 
-	res, err := d.DB.Query(query, idForUpdate)
+	input := make(map[string]interface{})
+	err := decodeBody(body, &input)
 	if err != nil {
 		return err
 	}
-	res.Close()
 
-	res, err = d.DB.Query("SELECT ROW_COUNT()")
-	if err != nil {
-		return err
+	if fieldErrors := validateInput(d.schema.Data[table], input, false); len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return nil
 	}
-	res.Next()
-	var updated int
-	err = res.Scan(&updated)
+
+	result, err := insertRecord(d.DB, table, d, input)
 	if err != nil {
 		return err
 	}
-	res.Close()
 
-	writeResponse(w, struct {
-		Updated int `json:"updated"`
-	}{1})
+	records := []map[string]interface{}{result}
+	format.apply(records)
+	writeResponse(w, records[0])
 	return nil
 }
 
-func createRow(table string, d *DbExplorer, w http.ResponseWriter, body []byte) error {
-	input := make(map[string]interface{})
-	err := json.Unmarshal(body, &input)
+// insertRecord inserts a validated record and returns its primary key
+func insertRecord(q Querier, table string, d *DbExplorer, input map[string]interface{}) (map[string]interface{}, error) {
+	fillMissingFields(d.schema.Data[table], input)
+
+	forInsertFieldNames, forInsertFieldValues, err := createDataForQuery(d.schema.Data[table], input)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	forInsertFieldNames, forInsertFieldValues, idFieldName := createDataForQuery(d.Data[table], input)
+	primaryKey := getPrimaryKey(table, d)
 
 	query := fmt.Sprintf(d.InsertQuery,
-		table,
-		strings.Join(forInsertFieldNames, "`, `"),
-		strings.Join(forInsertFieldValues, ", "),
-		idFieldName)
-	rs, err := d.DB.Query(query)
+		escapeIdentifier(table),
+		escapeIdentifiers(forInsertFieldNames),
+		strings.TrimSuffix(strings.Repeat("?, ", len(forInsertFieldValues)), ", "),
+		getAndFormatFieldNamesForQuery(primaryKey))
+	rs, err := q.Query(query, forInsertFieldValues...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	var resultId int
-	rs.Next()
-	err = rs.Scan(&resultId)
+	result, err := processRs(rs, primaryKey)
+	rs.Close()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	rs.Close()
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no id returned for inserted row")
+	}
+	return result[0], nil
+}
 
-	writeResponse(w, struct {
-		Id int `json:"id"`
-	}{resultId})
+func buildAssignments(fieldNames []string) string {
+	assignments := make([]string, len(fieldNames))
+	for i := range fieldNames {
+		assignments[i] = fmt.Sprintf("`%s` = ?", escapeIdentifier(fieldNames[i]))
+	}
+	return strings.Join(assignments, ", ")
+}
 
+// numbers are kept as json.Number so bigint values do not lose precision
+func decodeBody(body []byte, target interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(target); err != nil {
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_body", Message: "invalid json body", Detail: err.Error()}
+	}
 	return nil
 }
 
-func createDataForQuery(data []FieldMetaData, input map[string]interface{}) ([]string, []string, string) {
+func createDataForQuery(data []FieldMetaData, input map[string]interface{}) ([]string, []interface{}, error) {
 	forInsertFieldNames := make([]string, 0)
-	forInsertFieldValues := make([]string, 0)
-	var targetName string
+	forInsertFieldValues := make([]interface{}, 0)
 	for _, datum := range data {
 		value, exists := input[datum.Field]
 		if exists && datum.Extra.String != "auto_increment" {
-			forInsertFieldNames = append(forInsertFieldNames, datum.Field)
-			if strings.Contains(datum.Type, "text") || strings.Contains(datum.Type, "var") {
-				forInsertFieldValues = append(forInsertFieldValues, fmt.Sprintf("'%v'", value))
-			} else {
-				forInsertFieldValues = append(forInsertFieldValues, fmt.Sprintf("%v", value))
+			value, err := convertInput(value, datum)
+			if err != nil {
+				return nil, nil, err
 			}
-		}
-		if datum.Key.String == "PRI" {
-			targetName = datum.Field
+			forInsertFieldNames = append(forInsertFieldNames, datum.Field)
+			forInsertFieldValues = append(forInsertFieldValues, value)
 		}
 	}
-	return forInsertFieldNames, forInsertFieldValues, targetName
+	return forInsertFieldNames, forInsertFieldValues, nil
 }
 
-func getById(table string, d *DbExplorer, w http.ResponseWriter, restOfPath string) error {
-	id, err := strconv.Atoi(restOfPath[1:])
+func getById(table string, d *DbExplorer, w http.ResponseWriter, restOfPath string, params *ListParams) error {
+	id, err := parsePrimaryKey(table, d, restOfPath)
 	if err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
 	}
 
-	idFieeldName := getPrimaryKey(table, d)
-
-	rs, err := d.DB.Query(fmt.Sprintf(d.GetByIdQuery, getAndFormatFieldNamesForQuery(table, d), table, idFieeldName), id)
+	fields := withFields(params.Select, etagFields(table, d), embedFields(d.schema.Data[table], params.Embed))
+	condition := buildPrimaryKeyCondition(getPrimaryKey(table, d))
+	rs, err := d.DB.Query(fmt.Sprintf(d.GetByIdQuery, getAndFormatFieldNamesForQuery(fields), escapeIdentifier(table), condition), id...)
 	if err != nil {
 		return err
 	}
-	result, err := processRs(rs, d.Data[table])
+	result, err := processRs(rs, fields)
 	rs.Close()
 	if err != nil {
 		return err
@@ -259,126 +321,379 @@ func getById(table string, d *DbExplorer, w http.ResponseWriter, restOfPath stri
 		return nil
 	}
 
+	etag, err := computeETag(result[0], etagFields(table, d))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+
+	err = embedRecords(d, result, params.Embed)
+	if err != nil {
+		return err
+	}
+	stripFields(result, fields[len(params.Select):])
+	params.Format.apply(result)
+
 	writeRecord(w, result[0])
 	return nil
 }
 
-func getPrimaryKey(table string, d *DbExplorer) string {
-	var idFieeldName string
-	for _, data := range d.Data[table] {
-		if data.Key.String == "PRI" {
-			idFieeldName = data.Field
-			break
+func getPrimaryKey(table string, d *DbExplorer) []FieldMetaData {
+	return d.schema.PrimaryKeys[table]
+}
+
+func parsePrimaryKey(table string, d *DbExplorer, restOfPath string) ([]interface{}, error) {
+	parts := strings.Split(restOfPath[1:], ",")
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s", part)
+		}
+		parts[i] = unescaped
+	}
+	return parseKeyValues(table, d, parts)
+}
+
+func parseKeyValues(table string, d *DbExplorer, parts []string) ([]interface{}, error) {
+	primaryKey := getPrimaryKey(table, d)
+	if len(primaryKey) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key", table)
+	}
+	if len(parts) != len(primaryKey) {
+		return nil, fmt.Errorf("expected %d key values, got %d", len(primaryKey), len(parts))
+	}
+
+	values := make([]interface{}, len(parts))
+	for i, part := range parts {
+		value, err := convertQueryValue(part, primaryKey[i])
+		if err != nil {
+			return nil, fmt.Errorf("field %s have invalid value", primaryKey[i].Field)
 		}
+		values[i] = value
 	}
-	return idFieeldName
+	return values, nil
 }
 
-func getRows(table string, d *DbExplorer, w http.ResponseWriter) error {
-	rs, err := d.DB.Query(fmt.Sprintf(d.GetQuery, getAndFormatFieldNamesForQuery(table, d), table))
+func buildPrimaryKeyCondition(primaryKey []FieldMetaData) string {
+	conditions := make([]string, len(primaryKey))
+	for i, datum := range primaryKey {
+		conditions[i] = fmt.Sprintf("`%s` = ?", escapeIdentifier(datum.Field))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func getRows(table string, d *DbExplorer, w http.ResponseWriter, params *ListParams) error {
+	fields := withFields(params.Select, embedFields(d.schema.Data[table], params.Embed))
+	where, args := buildWhereClause(params.Filters)
+	order := buildOrderClause(params.Order)
+	rs, err := d.DB.Query(fmt.Sprintf(d.GetQuery, getAndFormatFieldNamesForQuery(fields), escapeIdentifier(table), where, order), args...)
 	if err != nil {
 		return err
 	}
-	result, err := processRs(rs, d.Data[table])
+	result, err := processRs(rs, fields)
 	rs.Close()
 	if err != nil {
 		return err
 	}
 
-	writeRecords(w, result)
+	err = embedRecords(d, result, params.Embed)
+	if err != nil {
+		return err
+	}
+	stripFields(result, fields[len(params.Select):])
+
+	var meta map[string]interface{}
+	if params.Count != "" {
+		total, err := countRows(table, params, d)
+		if err != nil {
+			return err
+		}
+		meta = map[string]interface{}{"total": total, "limit": nil, "offset": 0, "has_more": false}
+	}
+
+	params.Format.apply(result)
+	writeRecords(w, result, meta)
 	return nil
 }
 
-func getWithLimitAndOffset(table string, limitAndOffset string, d *DbExplorer, w http.ResponseWriter) error {
-	limit, err := extractLimitOrOffset(limitAndOffset, "limit", 5)
+func getWithLimitAndOffset(table string, params *ListParams, d *DbExplorer, w http.ResponseWriter) error {
+	where, args := buildWhereClause(params.Filters)
+	order := buildOrderClause(params.Order)
+	limit := params.Limit
+	if params.Count != "" {
+		limit++
+	}
+	args = append(args, limit, params.Offset)
+
+	fields := withFields(params.Select, embedFields(d.schema.Data[table], params.Embed))
+	fullQuery := fmt.Sprintf(d.LimitOffsetQuery, getAndFormatFieldNamesForQuery(fields), escapeIdentifier(table), where, order)
+	rs, err := d.DB.Query(fullQuery, args...)
 	if err != nil {
 		return err
 	}
-	offset, err := extractLimitOrOffset(limitAndOffset, "offset", 0)
+	result, err := processRs(rs, fields)
+	rs.Close()
 	if err != nil {
 		return err
 	}
 
-	fieldNames := getAndFormatFieldNamesForQuery(table, d)
-	fullQuery := fmt.Sprintf(d.LimitOffsetQuery, fieldNames, table, limit, offset)
-	rs, err := d.DB.Query(fullQuery)
+	hasMore := len(result) > params.Limit
+	if hasMore {
+		result = result[:params.Limit]
+	}
+	err = embedRecords(d, result, params.Embed)
 	if err != nil {
 		return err
 	}
-	result, err := processRs(rs, d.Data[table])
+	stripFields(result, fields[len(params.Select):])
+
+	var meta map[string]interface{}
+	if params.Count != "" {
+		total, err := countRows(table, params, d)
+		if err != nil {
+			return err
+		}
+		meta = map[string]interface{}{"total": total, "limit": params.Limit, "offset": params.Offset, "has_more": hasMore}
+	}
+
+	params.Format.apply(result)
+	writeRecords(w, result, meta)
+	return nil
+}
+
+func getWithCursor(table string, params *ListParams, d *DbExplorer, w http.ResponseWriter) error {
+	orderFields := make([]FieldMetaData, len(params.Order))
+	for i, orderBy := range params.Order {
+		orderFields[i] = orderBy.Field
+	}
+	fields := withFields(params.Select, orderFields, embedFields(d.schema.Data[table], params.Embed))
+
+	where, args := buildWhereClause(params.Filters)
+	if params.After != nil {
+		condition, conditionArgs := buildCursorCondition(params.Order, params.After)
+		if where == "" {
+			where = " WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+		args = append(args, conditionArgs...)
+	}
+	order := buildOrderClause(params.Order)
+	args = append(args, params.Limit+1)
+
+	fullQuery := fmt.Sprintf(d.CursorQuery, getAndFormatFieldNamesForQuery(fields), escapeIdentifier(table), where, order)
+	rs, err := d.DB.Query(fullQuery, args...)
 	if err != nil {
 		return err
 	}
+	result, err := processRs(rs, fields)
 	rs.Close()
+	if err != nil {
+		return err
+	}
 
-	writeRecords(w, result)
+	var nextCursor interface{}
+	if len(result) > params.Limit {
+		result = result[:params.Limit]
+		nextCursor, err = encodeCursor(result[len(result)-1], params.Order)
+		if err != nil {
+			return err
+		}
+	}
+	err = embedRecords(d, result, params.Embed)
+	if err != nil {
+		return err
+	}
+	stripFields(result, fields[len(params.Select):])
+
+	meta := map[string]interface{}{"next_cursor": nextCursor}
+	if params.Count != "" {
+		total, err := countRows(table, params, d)
+		if err != nil {
+			return err
+		}
+		meta["total"] = total
+		meta["limit"] = params.Limit
+		meta["has_more"] = nextCursor != nil
+	}
+
+	params.Format.apply(result)
+	writeRecords(w, result, meta)
 	return nil
 }
 
+func countRows(table string, params *ListParams, d *DbExplorer) (int64, error) {
+	where, args := buildWhereClause(params.Filters)
+	if params.Count == "estimated" && where == "" {
+		var estimate sql.NullInt64
+		err := d.DB.QueryRow(d.EstimatedCountQuery, table).Scan(&estimate)
+		if err != nil {
+			return 0, err
+		}
+		if estimate.Valid {
+			return estimate.Int64, nil
+		}
+	}
+
+	var total int64
+	err := d.DB.QueryRow(fmt.Sprintf(d.CountQuery, escapeIdentifier(table), where), args...).Scan(&total)
+	return total, err
+}
+
 func NewDbExplorer(db *sql.DB) (*DbExplorer, error) {
-	tablesRs, err := db.Query("SHOW TABLES;")
+	err := checkTimeZone(db)
 	if err != nil {
 		return nil, err
 	}
 
-	tablesData := make(map[string][]FieldMetaData)
-	for tablesRs.Next() {
-		var name string
-		if err := tablesRs.Scan(&name); err != nil {
-			return nil, err
-		}
+	schema, err := loadSchema(db)
+	if err != nil {
+		return nil, err
+	}
 
-		tablesData[name] = nil
+	reference, err := regexp.Compile(`^(\d+)\.(.+)$`)
+	if err != nil {
+		return nil, err
+	}
+	currentSchema := &atomic.Pointer[Schema]{}
+	currentSchema.Store(schema)
+
+	return &DbExplorer{
+		DB:                  db,
+		currentSchema:       currentSchema,
+		reloadMutex:         &sync.Mutex{},
+		Routes:              newRoutes(),
+		ReferenceRegexp:     reference,
+		VersionColumns:      make(map[string]string),
+		GetQuery:            "SELECT `%s` FROM `%s`%s%s",
+		LimitOffsetQuery:    "SELECT `%s` FROM `%s`%s%s LIMIT ? OFFSET ?",
+		CursorQuery:         "SELECT `%s` FROM `%s`%s%s LIMIT ?",
+		CountQuery:          "SELECT COUNT(*) FROM `%s`%s",
+		EstimatedCountQuery: "SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+		EmbedQuery:          "SELECT `%s` FROM `%s` WHERE (`%s`) IN (%s)",
+		SearchQuery:         "SELECT `%s`, %s AS `_score` FROM `%s` WHERE %s%s LIMIT ? OFFSET ?",
+		AggregateQuery:      "SELECT %s FROM `%s`%s%s%s",
+		DistinctQuery:       "SELECT `%s`, COUNT(*) FROM `%s`%s GROUP BY `%s` ORDER BY COUNT(*) DESC, `%s` LIMIT ?",
+		GetByIdQuery:        "SELECT `%s` FROM `%s` WHERE %s",
+		InsertQuery:         "INSERT INTO `%s` (`%s`) VALUES (%s) RETURNING `%s`",
+		BulkInsertQuery:     "INSERT INTO `%s` (`%s`) VALUES %s RETURNING `%s`",
+		UpsertQuery:         "INSERT INTO `%s` (`%s`) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		UpdateQuery:         "UPDATE `%s` SET %s WHERE %s",
+		DeleteQuery:         "DELETE FROM `%s` WHERE %s",
+		BulkUpdateQuery:     "UPDATE `%s` SET %s%s",
+		BulkDeleteQuery:     "DELETE FROM `%s`%s",
+		BulkChunkSize:       1000,
+		MaxPlaceholders:     65535,
+		MaxAffectedRows:     1000,
+	}, nil
+}
+
+// loadSchema runs on every reload, so each result set is closed on errors as well
+func loadSchema(db *sql.DB) (*Schema, error) {
+	tablesData, err := loadTableNames(db)
+	if err != nil {
+		return nil, err
 	}
-	tablesRs.Close()
 
 	for tableName, _ := range tablesData {
-		fieldsRs, err := db.Query(fmt.Sprintf("SHOW FULL COLUMNS FROM `%s`;", tableName))
+		tablesData[tableName], err = loadFields(db, tableName)
 		if err != nil {
 			return nil, err
 		}
-		fieldMetaData := make([]FieldMetaData, 0)
-		for fieldsRs.Next() {
-			field := FieldMetaData{}
-			err := fieldsRs.Scan(
-				&field.Field, &field.Type, &field.Collation,
-				&field.Null, &field.Key, &field.Default,
-				&field.Extra, &field.Privileges, &field.Comment,
-			)
-			if err != nil {
-				return nil, err
-			}
-			fieldMetaData = append(fieldMetaData, field)
-		}
-		tablesData[tableName] = fieldMetaData
-		fieldsRs.Close()
 	}
 
-	limitOffset, err := regexp.Compile("^(limit=\\d|offset=\\d){1}(&limit=\\d|&offset=\\d)?$")
+	err = loadJSONColumns(db, tablesData)
 	if err != nil {
 		return nil, err
 	}
 
-	byId, err := regexp.Compile("^/\\d+$")
+	primaryKeys, err := loadPrimaryKeys(db, tablesData)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DbExplorer{
-		DB:                db,
-		Data:              tablesData,
-		TableNames:        extractTableNames(tablesData),
-		LimitOffsetRegexp: limitOffset,
-		ByIdRegexp:        byId,
-		GetQuery:          "SELECT `%s` FROM `%s`",
-		LimitOffsetQuery:  "SELECT `%s` FROM `%s` %s %s",
-		GetByIdQuery:      "SELECT `%s` FROM `%s` WHERE `%s` = ?",
-		InsertQuery:       "INSERT INTO `%s` (`%s`) VALUES (%s) RETURNING `%s`",
-		UpdateQuery:       "UPDATE `%s` SET %s WHERE `%s` = ?",
-		DeleteQuery:       "DELETE FROM `%s` WHERE `%s` = ?",
+	foreignKeys, err := loadForeignKeys(db)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := loadIndexes(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Schema{
+		TableNames:  extractTableNames(tablesData),
+		Data:        tablesData,
+		PrimaryKeys: primaryKeys,
+		ForeignKeys: foreignKeys,
+		Indexes:     indexes,
 	}, nil
 }
 
+func loadTableNames(db *sql.DB) (map[string][]FieldMetaData, error) {
+	rs, err := db.Query("SHOW TABLES;")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	tablesData := make(map[string][]FieldMetaData)
+	for rs.Next() {
+		var name string
+		if err := rs.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		tablesData[name] = nil
+	}
+	return tablesData, rs.Err()
+}
+
+func loadFields(db *sql.DB, tableName string) ([]FieldMetaData, error) {
+	rs, err := db.Query(fmt.Sprintf("SHOW FULL COLUMNS FROM `%s`;", escapeIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	fieldMetaData := make([]FieldMetaData, 0)
+	for rs.Next() {
+		field := FieldMetaData{}
+		err := rs.Scan(
+			&field.Field, &field.Type, &field.Collation,
+			&field.Null, &field.Key, &field.Default,
+			&field.Extra, &field.Privileges, &field.Comment,
+		)
+		if err != nil {
+			return nil, err
+		}
+		fieldMetaData = append(fieldMetaData, field)
+	}
+	return fieldMetaData, rs.Err()
+}
+
+func loadPrimaryKeys(db *sql.DB, tablesData map[string][]FieldMetaData) (map[string][]FieldMetaData, error) {
+	rs, err := db.Query("SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY TABLE_NAME, ORDINAL_POSITION")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	primaryKeys := make(map[string][]FieldMetaData)
+	for rs.Next() {
+		var tableName, columnName string
+		if err := rs.Scan(&tableName, &columnName); err != nil {
+			return nil, err
+		}
+		if datum := findField(tablesData[tableName], columnName); datum != nil {
+			primaryKeys[tableName] = append(primaryKeys[tableName], *datum)
+		}
+	}
+	return primaryKeys, rs.Err()
+}
+
 func writeTables(w http.ResponseWriter, tables []string) {
 	response := struct {
 		Tables []string `json:"tables"`
@@ -386,17 +701,12 @@ func writeTables(w http.ResponseWriter, tables []string) {
 	writeResponse(w, response)
 }
 
-func writeError(w http.ResponseWriter, error string, statusCode int) {
-	marshal, _ := json.Marshal(struct {
-		Error string `json:"error"`
-	}{error})
-	http.Error(w, string(marshal), statusCode)
-}
-
-func writeRecords(w http.ResponseWriter, records []map[string]interface{}) {
-	response := struct {
-		Tables []map[string]interface{} `json:"records"`
-	}{records}
+func writeRecords(w http.ResponseWriter, records []map[string]interface{}, meta map[string]interface{}) {
+	response := make(map[string]interface{}, len(meta)+1)
+	for key, value := range meta {
+		response[key] = value
+	}
+	response["records"] = records
 	writeResponse(w, response)
 }
 
@@ -412,8 +722,10 @@ func writeResponse(w http.ResponseWriter, response interface{}) {
 		Response interface{} `json:"response"`
 	}{response})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(bytes)
 }
 
@@ -424,6 +736,7 @@ func extractTableNames(data map[string][]FieldMetaData) []string {
 		tables = append(tables, k)
 		i++
 	}
+	sort.Strings(tables)
 	return tables
 }
 
@@ -435,58 +748,58 @@ func extractFieldNames(tableData []FieldMetaData) []string {
 	return fieldNames
 }
 
-func extractLimitOrOffset(limitAndOffset string, targetName string, defaultValue int) (string, error) {
-	var targetValue int
-	resultTemplate := fmt.Sprintf(" %s %%d", strings.ToUpper(targetName))
-	targetIndex := strings.Index(limitAndOffset, targetName)
-	if targetIndex == -1 {
-		return fmt.Sprintf(resultTemplate, defaultValue), nil
-	}
-	cutTarget := limitAndOffset[len(targetName)+targetIndex+1:]
-	ampersandIndex := strings.Index(cutTarget, "&")
-	if ampersandIndex == -1 {
-		var err error
-		targetValue, err = strconv.Atoi(cutTarget)
-		if err != nil {
-			return "", err
+func convertValue(value string, datum FieldMetaData) (interface{}, error) {
+	valueType := datum.Type
+	switch fieldKind(datum) {
+	case "bool":
+		if baseType(valueType) == "bit" {
+			return value != "" && value[len(value)-1] != 0, nil
 		}
-	} else {
-		var err error
-		targetValue, err = strconv.Atoi(cutTarget[:ampersandIndex])
+		atoi, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-	}
-
-	return fmt.Sprintf(resultTemplate, targetValue), nil
-}
-
-func extractFuncName(path string) string {
-	cutFirstSlash := path[1:]
-	tableNameEnd := strings.Index(cutFirstSlash, "/")
-	var tableName string
-	if tableNameEnd == -1 {
-		tableName = cutFirstSlash
-	} else {
-		tableName = cutFirstSlash[:tableNameEnd]
-	}
-	return tableName
-}
-
-func convertValue(value string, valueType string) (interface{}, error) {
-	if strings.Contains(valueType, "int") {
-		atoi, err := strconv.Atoi(value)
+		return atoi != 0, nil
+	case "int":
+		atoi, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
 			return nil, err
 		}
-		return atoi, nil
-	}
-	if strings.Contains(valueType, "float") {
-		atoi, err := strconv.ParseFloat(value, 64)
+		if integerBits(valueType) == 64 {
+			return BigInt(atoi), nil
+		}
+		return int(atoi), nil
+	case "uint":
+		atoi, err := strconv.ParseUint(value, 10, 64)
 		if err != nil {
 			return nil, err
 		}
-		return atoi, nil
+		if integerBits(valueType) == 64 {
+			return BigUint(atoi), nil
+		}
+		return int(atoi), nil
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "decimal":
+		return Decimal(value), nil
+	case "datetime":
+		parsed, err := time.Parse("2006-01-02 15:04:05.999999", value)
+		if err != nil {
+			return value, nil
+		}
+		return parsed.Format(time.RFC3339Nano), nil
+	case "json":
+		if json.Valid([]byte(value)) {
+			return json.RawMessage(value), nil
+		}
+	case "bit":
+		var bits uint64
+		for _, b := range []byte(value) {
+			bits = bits<<8 | uint64(b)
+		}
+		return bits, nil
+	case "binary":
+		return []byte(value), nil
 	}
 
 	return value, nil
@@ -494,6 +807,14 @@ func convertValue(value string, valueType string) (interface{}, error) {
 
 func processRs(rs *sql.Rows, tableData []FieldMetaData) ([]map[string]interface{}, error) {
 	countOfFields := len(tableData)
+	columns, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != countOfFields {
+		return nil, fmt.Errorf("expected %d columns, got %d", countOfFields, len(columns))
+	}
+
 	result := make([]map[string]interface{}, 0)
 	for rs.Next() {
 		convertedRs := make([]interface{}, countOfFields)
@@ -509,7 +830,7 @@ func processRs(rs *sql.Rows, tableData []FieldMetaData) ([]map[string]interface{
 
 		for i := range unconvertedRs {
 			if unconvertedRs[i] != nil {
-				value, err := convertValue(string(unconvertedRs[i]), tableData[i].Type)
+				value, err := convertValue(string(unconvertedRs[i]), tableData[i])
 				if err != nil {
 					return nil, err
 				}
@@ -524,13 +845,23 @@ func processRs(rs *sql.Rows, tableData []FieldMetaData) ([]map[string]interface{
 			resultMap[tableData[i].Field] = row
 		}
 		result = append(result, resultMap)
-
-		fmt.Sprintf("")
 	}
 
-	return result, nil
+	return result, rs.Err()
+}
+
+func getAndFormatFieldNamesForQuery(fields []FieldMetaData) string {
+	return escapeIdentifiers(extractFieldNames(fields))
+}
+
+func escapeIdentifier(name string) string {
+	return strings.ReplaceAll(name, "`", "``")
 }
 
-func getAndFormatFieldNamesForQuery(table string, d *DbExplorer) string {
-	return strings.Join(extractFieldNames(d.Data[table]), "`, `")
+func escapeIdentifiers(names []string) string {
+	escaped := make([]string, len(names))
+	for i, name := range names {
+		escaped[i] = escapeIdentifier(name)
+	}
+	return strings.Join(escaped, "`, `")
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type ForeignKey struct {
+	Name              string   `json:"name"`
+	Table             string   `json:"table"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+type Embed struct {
+	Name       string
+	ForeignKey ForeignKey
+	Reverse    bool
+}
+
+func loadForeignKeys(db *sql.DB) (map[string][]ForeignKey, error) {
+	rs, err := db.Query("SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME " +
+		"FROM information_schema.KEY_COLUMN_USAGE " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_SCHEMA = DATABASE() " +
+		"ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	foreignKeys := make(map[string][]ForeignKey)
+	for rs.Next() {
+		var name, table, column, referencedTable, referencedColumn string
+		if err := rs.Scan(&name, &table, &column, &referencedTable, &referencedColumn); err != nil {
+			return nil, err
+		}
+
+		keys := foreignKeys[table]
+		if len(keys) == 0 || keys[len(keys)-1].Name != name {
+			keys = append(keys, ForeignKey{Name: name, Table: table, ReferencedTable: referencedTable})
+		}
+		last := &keys[len(keys)-1]
+		last.Columns = append(last.Columns, column)
+		last.ReferencedColumns = append(last.ReferencedColumns, referencedColumn)
+		foreignKeys[table] = keys
+	}
+	return foreignKeys, rs.Err()
+}
+
+func parseEmbed(table string, d *DbExplorer, embedParam string) ([]Embed, error) {
+	embeds := make([]Embed, 0)
+	if embedParam == "" {
+		return embeds, nil
+	}
+
+	for _, name := range strings.Split(embedParam, ",") {
+		// the related record is written under the relation name
+		if findField(d.schema.Data[table], name) != nil {
+			return nil, fmt.Errorf("relation %s has the name of a column", name)
+		}
+
+		matches := make([]Embed, 0, 1)
+		for _, fk := range d.schema.ForeignKeys[table] {
+			if fk.ReferencedTable == name || (len(fk.Columns) == 1 && strings.TrimSuffix(fk.Columns[0], "_id") == name) {
+				matches = append(matches, Embed{Name: name, ForeignKey: fk})
+			}
+		}
+		for _, fk := range d.schema.ForeignKeys[name] {
+			if fk.ReferencedTable == table {
+				matches = append(matches, Embed{Name: name, ForeignKey: fk, Reverse: true})
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("unknown relation %s", name)
+		case 1:
+			embeds = append(embeds, matches[0])
+		default:
+			return nil, fmt.Errorf("ambiguous relation %s", name)
+		}
+	}
+	return embeds, nil
+}
+
+func embedFields(tableData []FieldMetaData, embeds []Embed) []FieldMetaData {
+	fields := make([]FieldMetaData, 0)
+	for _, embed := range embeds {
+		columns := embed.ForeignKey.Columns
+		if embed.Reverse {
+			columns = embed.ForeignKey.ReferencedColumns
+		}
+		for _, column := range columns {
+			if datum := findField(tableData, column); datum != nil {
+				fields = append(fields, *datum)
+			}
+		}
+	}
+	return fields
+}
+
+func embedRecords(d *DbExplorer, records []map[string]interface{}, embeds []Embed) error {
+	for _, embed := range embeds {
+		localColumns, remoteTable, remoteColumns := embed.ForeignKey.Columns, embed.ForeignKey.ReferencedTable, embed.ForeignKey.ReferencedColumns
+		if embed.Reverse {
+			localColumns, remoteTable, remoteColumns = embed.ForeignKey.ReferencedColumns, embed.ForeignKey.Table, embed.ForeignKey.Columns
+		}
+
+		related, err := loadRelated(d, records, localColumns, remoteTable, remoteColumns)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			var rows []map[string]interface{}
+			if key, ok := relationKey(record, localColumns); ok {
+				rows = related[key]
+			}
+			if embed.Reverse {
+				if rows == nil {
+					rows = make([]map[string]interface{}, 0)
+				}
+				record[embed.Name] = rows
+			} else if len(rows) > 0 {
+				record[embed.Name] = rows[0]
+			} else {
+				record[embed.Name] = nil
+			}
+		}
+	}
+	return nil
+}
+
+func loadRelated(d *DbExplorer, records []map[string]interface{}, localColumns []string, remoteTable string, remoteColumns []string) (map[string][]map[string]interface{}, error) {
+	related := make(map[string][]map[string]interface{})
+	seen := make(map[string]bool)
+	args := make([]interface{}, 0)
+	tuples := make([]string, 0)
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(localColumns)), ", ") + ")"
+	for _, record := range records {
+		key, ok := relationKey(record, localColumns)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		for _, column := range localColumns {
+			args = append(args, record[column])
+		}
+		tuples = append(tuples, tuple)
+	}
+	if len(tuples) == 0 {
+		return related, nil
+	}
+
+	remoteData := d.schema.Data[remoteTable]
+	query := fmt.Sprintf(d.EmbedQuery,
+		getAndFormatFieldNamesForQuery(remoteData),
+		escapeIdentifier(remoteTable),
+		escapeIdentifiers(remoteColumns),
+		strings.Join(tuples, ", "))
+	rs, err := d.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := processRs(rs, remoteData)
+	rs.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if key, ok := relationKey(row, remoteColumns); ok {
+			related[key] = append(related[key], row)
+		}
+	}
+	return related, nil
+}
+
+// records with a NULL in any of the columns reference nothing
+func relationKey(record map[string]interface{}, columns []string) (string, bool) {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		if record[column] == nil {
+			return "", false
+		}
+		parts[i] = fmt.Sprint(record[column])
+	}
+	return strings.Join(parts, "\x00"), true
+}
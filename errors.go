@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// APIError is the body of every error response, Code is stable
+// while Error and Detail are meant for people
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"error"`
+	Field     string       `json:"field,omitempty"`
+	Detail    string       `json:"detail,omitempty"`
+	Operation *int         `json:"operation,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func statusErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable_entity"
+	}
+	return "internal_error"
+}
+
+// mapError turns an error returned by a handler into a response,
+// MySQL errors caused by the request data get a client status
+func mapError(err error, table string, d *DbExplorer) *APIError {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError
+	}
+
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		message := mysqlError.Message
+		switch mysqlError.Number {
+		case 1062:
+			return &APIError{Status: http.StatusConflict, Code: "duplicate_entry", Message: "duplicate entry",
+				Field: indexField(d.schema.Indexes[table], quotedName(message, "for key ")), Detail: message}
+		case 1451:
+			return &APIError{Status: http.StatusConflict, Code: "foreign_key_violation",
+				Message: "record is referenced by other records", Detail: message}
+		case 1452:
+			return &APIError{Status: http.StatusUnprocessableEntity, Code: "foreign_key_violation",
+				Message: "referenced record does not exist", Field: quotedName(message, "FOREIGN KEY ("), Detail: message}
+		case 1406:
+			return &APIError{Status: http.StatusBadRequest, Code: "data_too_long", Message: "too long value",
+				Field: quotedName(message, "column "), Detail: message}
+		case 1048:
+			return &APIError{Status: http.StatusBadRequest, Code: "null_value", Message: "null value",
+				Field: quotedName(message, "Column "), Detail: message}
+		case 1364:
+			return &APIError{Status: http.StatusBadRequest, Code: "missing_value", Message: "missing value",
+				Field: quotedName(message, "Field "), Detail: message}
+		}
+	}
+
+	// the cause may contain queries or connection details, only the log gets it
+	log.Printf("internal error: %v", err)
+	return &APIError{Status: http.StatusInternalServerError, Code: "internal_error", Message: "internal error"}
+}
+
+// quotedName takes the name quoted with ' or ` right after the marker,
+// a schema prefix like in 'table.key' is dropped
+func quotedName(message string, marker string) string {
+	_, rest, found := strings.Cut(message, marker)
+	if !found || rest == "" || (rest[0] != '\'' && rest[0] != '`') {
+		return ""
+	}
+	end := strings.IndexByte(rest[1:], rest[0])
+	if end == -1 {
+		return ""
+	}
+	name := rest[1 : end+1]
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// duplicates are reported by index name, single column indexes name the field
+func indexField(indexes []Index, name string) string {
+	for _, index := range indexes {
+		if index.Name == name && len(index.Columns) == 1 {
+			return index.Columns[0]
+		}
+	}
+	return ""
+}
+
+func writeFailure(w http.ResponseWriter, err error, table string, d *DbExplorer) {
+	writeAPIError(w, mapError(err, table, d))
+}
+
+func writeAPIError(w http.ResponseWriter, apiError *APIError) {
+	marshal, _ := json.Marshal(apiError)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiError.Status)
+	w.Write(marshal)
+}
+
+func writeError(w http.ResponseWriter, error string, statusCode int) {
+	writeAPIError(w, &APIError{Status: statusCode, Code: statusErrorCode(statusCode), Message: error})
+}
+
+func writeFieldErrors(w http.ResponseWriter, fieldErrors []FieldError) {
+	writeAPIError(w, &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    "invalid_fields",
+		Message: joinFieldErrors(fieldErrors),
+		Fields:  fieldErrors,
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// etagFields are the fields a row version is computed from,
+// the configured version column or the whole row
+func etagFields(table string, d *DbExplorer) []FieldMetaData {
+	if datum := versionColumn(table, d); datum != nil {
+		return []FieldMetaData{*datum}
+	}
+	return d.schema.Data[table]
+}
+
+func versionColumn(table string, d *DbExplorer) *FieldMetaData {
+	column, exists := d.VersionColumns[table]
+	if !exists {
+		return nil
+	}
+	return findField(d.schema.Data[table], column)
+}
+
+// withVersionBump adds the increment of the version column to the assignments,
+// the server bumps it on every update so a stale If-Match never matches again
+func withVersionBump(table string, d *DbExplorer, assignments string) string {
+	datum := versionColumn(table, d)
+	if datum == nil {
+		return assignments
+	}
+	column := escapeIdentifier(datum.Field)
+	return fmt.Sprintf("%s, `%s` = `%s` + 1", assignments, column, column)
+}
+
+// versionFieldErrors refuses values sent for the version column
+func versionFieldErrors(table string, d *DbExplorer, input map[string]interface{}) []FieldError {
+	datum := versionColumn(table, d)
+	if datum == nil {
+		return nil
+	}
+	if _, exists := input[datum.Field]; !exists {
+		return nil
+	}
+	return []FieldError{{Field: datum.Field, Message: "read only value"}}
+}
+
+func computeETag(record map[string]interface{}, fields []FieldMetaData) (string, error) {
+	values := make([]interface{}, len(fields))
+	for i, datum := range fields {
+		values[i] = record[datum.Field]
+	}
+	if len(values) == 1 {
+		return fmt.Sprintf(`"%v"`, values[0]), nil
+	}
+
+	marshal, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(marshal)
+	return fmt.Sprintf(`"%x"`, sum[:16]), nil
+}
+
+// matchesETag compares the ETag of a row locked by lockRow with the If-Match header
+func matchesETag(record map[string]interface{}, fields []FieldMetaData, ifMatch string) (bool, error) {
+	etag, err := computeETag(record, fields)
+	if err != nil {
+		return false, err
+	}
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
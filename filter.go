@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Filter struct {
+	Field    FieldMetaData
+	Operator string
+	Values   []interface{}
+}
+
+type ListParams struct {
+	Select  []FieldMetaData
+	Embed   []Embed
+	Filters []Filter
+	Order   []OrderBy
+	Limit   int
+	Offset  int
+	Paged   bool
+	Cursor  bool
+	After   []interface{}
+	Count   string
+	Format  Format
+}
+
+func parseRecordParams(table string, d *DbExplorer, query url.Values) (*ListParams, error) {
+	params := &ListParams{}
+	fields, err := parseSelect(d.schema.Data[table], query.Get("select"))
+	if err != nil {
+		return nil, err
+	}
+	params.Select = fields
+
+	embeds, err := parseEmbed(table, d, query.Get("embed"))
+	if err != nil {
+		return nil, err
+	}
+	params.Embed = embeds
+
+	params.Format, err = parseFormat(query)
+	if err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func parseListParams(table string, d *DbExplorer, query url.Values) (*ListParams, error) {
+	params, err := parseRecordParams(table, d, query)
+	if err != nil {
+		return nil, err
+	}
+	params.Limit, err = parseLimitOrOffset(query, "limit", 5)
+	if err != nil {
+		return nil, err
+	}
+	params.Offset, err = parseLimitOrOffset(query, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	params.Paged = query.Has("limit") || query.Has("offset")
+	params.Count = query.Get("count")
+	if params.Count != "" && params.Count != "exact" && params.Count != "estimated" {
+		return nil, fmt.Errorf("invalid count %s", params.Count)
+	}
+
+	filters, err := parseFilters(d.schema.Data[table], query)
+	if err != nil {
+		return nil, err
+	}
+	params.Filters = filters
+
+	order, err := parseOrder(d.schema.Data[table], query.Get("order"))
+	if err != nil {
+		return nil, err
+	}
+	params.Order = withPrimaryKeyOrder(order, getPrimaryKey(table, d))
+
+	if query.Has("after") {
+		if query.Has("offset") {
+			return nil, fmt.Errorf("after can not be used with offset")
+		}
+		if len(getPrimaryKey(table, d)) == 0 {
+			return nil, fmt.Errorf("table %s has no primary key", table)
+		}
+		if params.Limit == 0 {
+			return nil, fmt.Errorf("after needs a positive limit")
+		}
+		params.Cursor = true
+		if after := query.Get("after"); after != "" {
+			params.After, err = decodeCursor(after, params.Order)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return params, nil
+}
+
+func parseSelect(tableData []FieldMetaData, selectParam string) ([]FieldMetaData, error) {
+	if selectParam == "" {
+		return tableData, nil
+	}
+
+	fields := make([]FieldMetaData, 0)
+	for _, name := range strings.Split(selectParam, ",") {
+		datum := findField(tableData, name)
+		if datum == nil {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+		if findField(fields, name) == nil {
+			fields = append(fields, *datum)
+		}
+	}
+	return fields, nil
+}
+
+func withFields(fields []FieldMetaData, extra ...[]FieldMetaData) []FieldMetaData {
+	result := append([]FieldMetaData{}, fields...)
+	for _, group := range extra {
+		for _, datum := range group {
+			if findField(result, datum.Field) == nil {
+				result = append(result, datum)
+			}
+		}
+	}
+	return result
+}
+
+func stripFields(records []map[string]interface{}, fields []FieldMetaData) {
+	for _, record := range records {
+		for _, field := range fields {
+			delete(record, field.Field)
+		}
+	}
+}
+
+func parseLimitOrOffset(query url.Values, targetName string, defaultValue int) (int, error) {
+	if !query.Has(targetName) {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(query.Get(targetName))
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s %s", targetName, query.Get(targetName))
+	}
+	return value, nil
+}
+
+func isReservedParam(name string) bool {
+	switch name {
+	case "limit", "offset", "order", "select", "after", "count", "embed", "decimals", "bigints":
+		return true
+	}
+	return false
+}
+
+// parseColumnFilters is parseFilters for endpoints without paging and projection,
+// reserved names other than the given params are rejected instead of skipped
+func parseColumnFilters(tableData []FieldMetaData, query url.Values, params ...string) ([]Filter, error) {
+	filterQuery := url.Values{}
+	for name, values := range query {
+		if slices.Contains(params, name) {
+			continue
+		}
+		if isReservedParam(name) {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+		filterQuery[name] = values
+	}
+	return parseFilters(tableData, filterQuery)
+}
+
+func parseFilters(tableData []FieldMetaData, query url.Values) ([]Filter, error) {
+	for name := range query {
+		if !isReservedParam(name) && findField(tableData, name) == nil {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+	}
+
+	filters := make([]Filter, 0)
+	for _, datum := range tableData {
+		for _, expression := range query[datum.Field] {
+			filter, err := parseFilter(datum, expression)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+	return filters, nil
+}
+
+func parseFilter(datum FieldMetaData, expression string) (Filter, error) {
+	operator, rawValue, found := strings.Cut(expression, ".")
+	if !found {
+		return Filter{}, fmt.Errorf("invalid filter for field %s", datum.Field)
+	}
+
+	filter := Filter{Field: datum, Operator: operator}
+	switch operator {
+	case "eq", "neq", "gt", "gte", "lt", "lte":
+		value, err := convertQueryValue(rawValue, datum)
+		if err != nil {
+			return Filter{}, fmt.Errorf("field %s have invalid value", datum.Field)
+		}
+		filter.Values = []interface{}{value}
+	case "like":
+		filter.Values = []interface{}{rawValue}
+	case "in":
+		rawValue = strings.TrimSuffix(strings.TrimPrefix(rawValue, "("), ")")
+		for _, part := range strings.Split(rawValue, ",") {
+			value, err := convertQueryValue(part, datum)
+			if err != nil {
+				return Filter{}, fmt.Errorf("field %s have invalid value", datum.Field)
+			}
+			filter.Values = append(filter.Values, value)
+		}
+	case "is":
+		if rawValue != "null" && rawValue != "notnull" {
+			return Filter{}, fmt.Errorf("field %s have invalid value", datum.Field)
+		}
+		filter.Operator = rawValue
+	default:
+		return Filter{}, fmt.Errorf("unknown operator %s for field %s", operator, datum.Field)
+	}
+	return filter, nil
+}
+
+func buildWhereClause(filters []Filter) (string, []interface{}) {
+	if len(filters) == 0 {
+		return "", nil
+	}
+
+	conditions := make([]string, len(filters))
+	args := make([]interface{}, 0, len(filters))
+	for i, filter := range filters {
+		conditions[i] = filterCondition(filter)
+		args = append(args, filter.Values...)
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func filterCondition(filter Filter) string {
+	column := fmt.Sprintf("`%s`", escapeIdentifier(filter.Field.Field))
+	switch filter.Operator {
+	case "eq":
+		return column + " = ?"
+	case "neq":
+		return column + " <> ?"
+	case "gt":
+		return column + " > ?"
+	case "gte":
+		return column + " >= ?"
+	case "lt":
+		return column + " < ?"
+	case "lte":
+		return column + " <= ?"
+	case "like":
+		return column + " LIKE ?"
+	case "in":
+		return fmt.Sprintf("%s IN (%s)", column, strings.TrimSuffix(strings.Repeat("?, ", len(filter.Values)), ", "))
+	case "null":
+		return column + " IS NULL"
+	default:
+		return column + " IS NOT NULL"
+	}
+}
+
+func findField(tableData []FieldMetaData, name string) *FieldMetaData {
+	for i := range tableData {
+		if tableData[i].Field == name {
+			return &tableData[i]
+		}
+	}
+	return nil
+}
@@ -15,7 +15,8 @@ var (
 	// вы можете изменить этот на тот который вам нужен
 	// docker run -p 3306:3306 -v $(PWD):/docker-entrypoint-initdb.d -e MYSQL_ROOT_PASSWORD=1234 -e MYSQL_DATABASE=golang -d mysql
 	// DSN = "root@tcp(localhost:3306)/golang2017?charset=utf8"
-	DSN = "root:love@tcp(localhost:3306)/photolist?charset=utf8"
+	// time_zone keeps datetime values in UTC, NewDbExplorer refuses other zones
+	DSN = "root:love@tcp(localhost:3306)/photolist?charset=utf8&time_zone=%27%2B00%3A00%27"
 )
 
 func main() {
@@ -26,6 +26,9 @@ type Case struct {
 	Status int
 	Result interface{}
 	Body   interface{}
+	// заголовки запроса и ожидаемые заголовки ответа
+	Headers         map[string]string
+	ResponseHeaders map[string]string
 }
 
 var (
@@ -117,6 +120,7 @@ func TestApis(t *testing.T) {
 			Path:   "/unknown_table",
 			Status: http.StatusNotFound,
 			Result: CR{
+				"code":  "not_found",
 				"error": "unknown table",
 			},
 		},
@@ -190,6 +194,7 @@ func TestApis(t *testing.T) {
 			Path:   "/items/100500",
 			Status: http.StatusNotFound,
 			Result: CR{
+				"code":  "not_found",
 				"error": "record not found",
 			},
 		},
@@ -317,7 +322,7 @@ func TestApis(t *testing.T) {
 				"error": "field id have invalid type",
 			},
 		},*/
-		/*Case{TODO надо пофиксить, но лень
+		Case{
 			Path:   "/items/3",
 			Method: http.MethodPost,
 			Status: http.StatusBadRequest,
@@ -325,10 +330,14 @@ func TestApis(t *testing.T) {
 				"title": 42,
 			},
 			Result: CR{
+				"code":  "invalid_fields",
 				"error": "field title have invalid type",
+				"fields": []CR{
+					CR{"field": "title", "message": "invalid type"},
+				},
 			},
-		},*/
-		/*Case{TODO надо пофиксить, но лень
+		},
+		Case{
 			Path:   "/items/3",
 			Method: http.MethodPost,
 			Status: http.StatusBadRequest,
@@ -336,11 +345,15 @@ func TestApis(t *testing.T) {
 				"title": nil,
 			},
 			Result: CR{
+				"code":  "invalid_fields",
 				"error": "field title have invalid type",
+				"fields": []CR{
+					CR{"field": "title", "message": "invalid type"},
+				},
 			},
-		},*/
+		},
 
-		/*Case{TODO надо пофиксить, но лень
+		Case{
 			Path:   "/items/3",
 			Method: http.MethodPost,
 			Status: http.StatusBadRequest,
@@ -348,12 +361,16 @@ func TestApis(t *testing.T) {
 				"updated": 42,
 			},
 			Result: CR{
+				"code":  "invalid_fields",
 				"error": "field updated have invalid type",
+				"fields": []CR{
+					CR{"field": "updated", "message": "invalid type"},
+				},
 			},
-		},*/
+		},
 
 		// удаление
-		/*Case{ //19 TODO некорректно отрабатывает SELECT ROW_COUNT()
+		Case{ //19
 			Path:   "/items/3",
 			Method: http.MethodDelete,
 			Result: CR{
@@ -361,20 +378,21 @@ func TestApis(t *testing.T) {
 					"deleted": 1,
 				},
 			},
-		},*/
+		},
 		Case{
 			Path:   "/items/3",
 			Method: http.MethodDelete,
+			Status: http.StatusNotFound,
 			Result: CR{
-				"response": CR{
-					"deleted": 0,
-				},
+				"code":  "not_found",
+				"error": "record not found",
 			},
 		},
 		Case{
 			Path:   "/items/3",
 			Status: http.StatusNotFound,
 			Result: CR{
+				"code":  "not_found",
 				"error": "record not found",
 			},
 		},
@@ -433,7 +451,11 @@ func TestApis(t *testing.T) {
 				"user_id": 1, // primary key нельзя обновлять у существующей записи
 			},
 			Result: CR{
+				"code":  "invalid_fields",
 				"error": "field user_id have invalid type",
+				"fields": []CR{
+					CR{"field": "user_id", "message": "invalid type"},
+				},
 			},
 		},
 		// не забываем про sql-инъекции
@@ -468,31 +490,23 @@ func TestApis(t *testing.T) {
 			},
 		},
 		// тут тоже возможна sql-инъекция
-		// если пришло не число на вход - берём дефолтное значене для лимита-оффсета
+		// если пришло не число на вход - отвечаем 400
 		Case{ //28
-			Path:  "/users",
-			Query: "limit=1'&offset=1\"",
+			Path:   "/users",
+			Query:  "limit=1'&offset=1\"",
+			Status: http.StatusBadRequest,
 			Result: CR{
-				"response": CR{
-					"records": []CR{
-						CR{
-							"user_id":  1,
-							"login":    "rvasily",
-							"password": "love",
-							"email":    "rvasily@example.com",
-							"info":     "try update",
-							"updated":  "now",
-						},
-						CR{
-							"user_id":  2,
-							"login":    "qwerty'",
-							"password": "love\"",
-							"email":    "",
-							"info":     "",
-							"updated":  nil,
-						},
-					},
-				},
+				"code":  "bad_request",
+				"error": "invalid limit 1'",
+			},
+		},
+		Case{
+			Path:   "/users",
+			Query:  "offset=-3",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "invalid offset -3",
 			},
 		},
 	}
@@ -500,50 +514,2711 @@ func TestApis(t *testing.T) {
 	runCases(t, ts, db, cases)
 }
 
-func runCases(t *testing.T, ts *httptest.Server, db *sql.DB, cases []Case) {
-	for idx, item := range cases {
-		var (
-			err      error
-			result   interface{}
-			expected interface{}
-			req      *http.Request
-		)
-
-		caseName := fmt.Sprintf("case %d: [%s] %s %s", idx, item.Method, item.Path, item.Query)
+func TestListQueries(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
 
-		// если у вас случилась это ошибка - значит вы не делаете где-то rows.Close и у вас текут соединения с базой
-		// если такое случилось на первом тесте - значит вы не закрываете коннект где-то при иницаилизации в NewDbExplorer
-		if db.Stats().OpenConnections != 1 {
-			t.Fatalf("[%s] you have %d open connections, must be 1", caseName, db.Stats().OpenConnections)
-		}
+	PrepareTestApis(db)
+	defer CleanupTestApis(db)
 
-		if item.Method == "" || item.Method == http.MethodGet {
-			req, err = http.NewRequest(item.Method, ts.URL+item.Path+"?"+item.Query, nil)
-		} else {
-			data, err := json.Marshal(item.Body)
-			if err != nil {
-				panic(err)
-			}
-			reqBody := bytes.NewReader(data)
-			req, err = http.NewRequest(item.Method, ts.URL+item.Path, reqBody)
-			req.Header.Add("Content-Type", "application/json")
-		}
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			t.Fatalf("[%s] request error: %v", caseName, err)
-			continue
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
+	ts := httptest.NewServer(handler)
 
-		// fmt.Printf("[%s] body: %s\n", caseName, string(body))
-		if item.Status == 0 {
-			item.Status = http.StatusOK
-		}
+	memcache := CR{
+		"id":          2,
+		"title":       "memcache",
+		"description": "Рассказать про мемкеш с примером использования",
+		"updated":     nil,
+	}
 
-		if resp.StatusCode != item.Status {
-			t.Fatalf("[%s] expected http status %v, got %v", caseName, item.Status, resp.StatusCode)
+	cases := []Case{
+		Case{
+			Path:  "/items",
+			Query: "title=eq.memcache",
+			Result: CR{
+				"response": CR{
+					"records": []CR{memcache},
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "id=gt.1&updated=is.null",
+			Result: CR{
+				"response": CR{
+					"records": []CR{memcache},
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "id=in.(1,2)&limit=1&offset=1",
+			Result: CR{
+				"response": CR{
+					"records": []CR{memcache},
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "title=like.%25base%25&id=neq.2",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id":          1,
+							"title":       "database/sql",
+							"description": "Рассказать про базы данных",
+							"updated":     "rvasily",
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "order=updated.asc.nullslast,id.desc&limit=1",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id":          1,
+							"title":       "database/sql",
+							"description": "Рассказать про базы данных",
+							"updated":     "rvasily",
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "order=id.desc&offset=1",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id":          1,
+							"title":       "database/sql",
+							"description": "Рассказать про базы данных",
+							"updated":     "rvasily",
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "order=title.desc&limit=1",
+			Result: CR{
+				"response": CR{
+					"records": []CR{memcache},
+				},
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "order=unknown.asc",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown field unknown",
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "order=id.sideways",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "invalid order sideways for field id",
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "select=id,title&id=eq.2",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id":    2,
+							"title": "memcache",
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/items/1",
+			Query: "select=title,updated",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title":   "database/sql",
+						"updated": "rvasily",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/1",
+			Query:  "select=title,secret",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown field secret",
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "after=&limit=1&select=title",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"title": "database/sql",
+						},
+					},
+					"next_cursor": "WzFd",
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "after=WzFd&limit=1&select=title",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"title": "memcache",
+						},
+					},
+					"next_cursor": nil,
+				},
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "after=WzFd&offset=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "after can not be used with offset",
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "after=garbage",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "invalid cursor",
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "after=&limit=0",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "after needs a positive limit",
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "count=exact&limit=1&select=id",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id": 1,
+						},
+					},
+					"total":    2,
+					"limit":    1,
+					"offset":   0,
+					"has_more": true,
+				},
+			},
+		},
+		Case{
+			Path:  "/items",
+			Query: "count=estimated&title=eq.memcache&select=id",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id": 2,
+						},
+					},
+					"total":    1,
+					"limit":    nil,
+					"offset":   0,
+					"has_more": false,
+				},
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "count=roughly",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "invalid count roughly",
+			},
+		},
+		Case{
+			Path:  "/items/search",
+			Query: "q=memcache&select=id,title",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id":     2,
+							"title":  "memcache",
+							"_score": 1,
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/items/search",
+			Query: "q=100%25",
+			Result: CR{
+				"response": CR{
+					"records": []CR{},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/search",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "q is required",
+			},
+		},
+		Case{
+			Path:  "/items/aggregate",
+			Query: "group=updated&agg=count(*),max(id)",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"updated":  nil,
+							"count(*)": 1,
+							"max(id)":  2,
+						},
+						CR{
+							"updated":  "rvasily",
+							"count(*)": 1,
+							"max(id)":  1,
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/items/aggregate",
+			Query: "agg=sum(id),avg(id)&id=gt.0",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"sum(id)": 3,
+							"avg(id)": 1.5,
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/aggregate",
+			Query:  "agg=median(id)",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown aggregate function median",
+			},
+		},
+		Case{
+			Path:   "/items/aggregate",
+			Query:  "agg=sum(title)",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "aggregate sum needs a numeric field title",
+			},
+		},
+		Case{
+			Path:   "/items/aggregate",
+			Query:  "agg=count(*)&limit=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown parameter limit",
+			},
+		},
+		Case{
+			Path:   "/items/updated/distinct",
+			Query:  "order=id.desc",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown parameter order",
+			},
+		},
+		Case{
+			Path:  "/items/updated/distinct",
+			Query: "limit=50",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"value": nil, "count": 1},
+						CR{"value": "rvasily", "count": 1},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/unknown/distinct",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown field unknown",
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "unknown=eq.1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown field unknown",
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "id=gt.abc",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "field id have invalid value",
+			},
+		},
+		Case{
+			Path:   "/items",
+			Query:  "id=between.1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown operator between for field id",
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestSearch(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS articles;`,
+		`CREATE TABLE articles (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  title varchar(255) NOT NULL,
+  body text NOT NULL,
+  views bigint(20) NOT NULL DEFAULT 0,
+  PRIMARY KEY (id),
+  FULLTEXT KEY title (title)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO articles (id, title, body, views) VALUES
+(1, 'mysql tutorial', 'introduction', 10),
+(2, 'cooking', 'no mysql here', 20),
+(3, 'gardening', 'plants', 30);`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS articles;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	// FULLTEXT relevance is not a stable number, so only the order and the sign are checked
+	resp, err := client.Get(ts.URL + "/articles/search?q=mysql&select=id,views&bigints=string")
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	defer resp.Body.Close()
+	result := struct {
+		Response struct {
+			Records []struct {
+				Id    int     `json:"id"`
+				Views string  `json:"views"`
+				Score float64 `json:"_score"`
+			} `json:"records"`
+		} `json:"response"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatalf("cant unpack result: %v", err)
+	}
+	records := result.Response.Records
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %#v", records)
+	}
+	// the LIKE match on body scores 1, the FULLTEXT match on title less
+	if records[0].Id != 2 || records[0].Score != 1 || records[0].Views != "20" {
+		t.Errorf("expected the body match first, got %#v", records[0])
+	}
+	if records[1].Id != 1 || records[1].Score <= 0 || records[1].Views != "10" {
+		t.Errorf("expected the title match second, got %#v", records[1])
+	}
+
+	cases := []Case{
+		Case{
+			Path:  "/articles/search",
+			Query: "q=plants&select=title",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"title":  "gardening",
+							"_score": 1,
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/articles/search",
+			Query: "q=nothing",
+			Result: CR{
+				"response": CR{
+					"records": []CR{},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestCompositeKeys(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS order_items;`,
+		`CREATE TABLE order_items (
+  item_id int(11) NOT NULL,
+  order_id int(11) NOT NULL,
+  qty int(11) NOT NULL,
+  PRIMARY KEY (order_id, item_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO order_items (item_id, order_id, qty) VALUES (7, 42, 3);`,
+		`DROP TABLE IF EXISTS sessions;`,
+		`CREATE TABLE sessions (
+  token varchar(64) NOT NULL,
+  login varchar(255) NOT NULL,
+  PRIMARY KEY (token)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO sessions (token, login) VALUES ('3f2a-bc', 'rvasily');`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS order_items, sessions;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path: "/order_items/42,7",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"item_id":  7,
+						"order_id": 42,
+						"qty":      3,
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/order_items/42",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "expected 2 key values, got 1",
+			},
+		},
+		Case{
+			Path:   "/order_items/7,42",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"code":  "not_found",
+				"error": "record not found",
+			},
+		},
+		Case{
+			Path:   "/order_items/42,7",
+			Method: http.MethodPost,
+			Body: CR{
+				"qty": 5,
+			},
+			Result: CR{
+				"response": CR{
+					"updated": 1,
+				},
+			},
+		},
+		Case{
+			Path: "/sessions/3f2a-bc",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"token": "3f2a-bc",
+						"login": "rvasily",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/sessions/",
+			Method: http.MethodPut,
+			Body: CR{
+				"token": "9d1e-ff",
+				"login": "qwerty",
+			},
+			Result: CR{
+				"response": CR{
+					"token": "9d1e-ff",
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestRelations(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS comments, posts, authors;`,
+		`CREATE TABLE authors (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`CREATE TABLE posts (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  author_id int(11) DEFAULT NULL,
+  title varchar(255) NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (author_id) REFERENCES authors (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`CREATE TABLE comments (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  post_id int(11) NOT NULL,
+  author int(11) DEFAULT NULL,
+  body text NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (post_id) REFERENCES posts (id),
+  FOREIGN KEY (author) REFERENCES authors (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO authors (id, name) VALUES (1, 'rvasily');`,
+		`INSERT INTO posts (id, author_id, title) VALUES (1, 1, 'database/sql'), (2, NULL, 'memcache');`,
+		`INSERT INTO comments (id, post_id, author, body) VALUES (1, 1, 1, 'first'), (2, 1, NULL, 'second');`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS comments, posts, authors;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:  "/posts/1",
+			Query: "embed=author",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":        1,
+						"author_id": 1,
+						"title":     "database/sql",
+						"author": CR{
+							"id":   1,
+							"name": "rvasily",
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/posts",
+			Query: "embed=authors,comments&select=title",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"title": "database/sql",
+							"authors": CR{
+								"id":   1,
+								"name": "rvasily",
+							},
+							"comments": []CR{
+								CR{"id": 1, "post_id": 1, "author": 1, "body": "first"},
+								CR{"id": 2, "post_id": 1, "author": nil, "body": "second"},
+							},
+						},
+						CR{
+							"title":    "memcache",
+							"authors":  nil,
+							"comments": []CR{},
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/posts",
+			Query:  "embed=tags",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown relation tags",
+			},
+		},
+		Case{
+			Path:   "/comments/1",
+			Query:  "embed=author",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "relation author has the name of a column",
+			},
+		},
+		Case{
+			Path:  "/comments/1",
+			Query: "embed=authors&select=body",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"body": "first",
+						"authors": CR{
+							"id":   1,
+							"name": "rvasily",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestWriteEscaping(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	PrepareTestApis(db)
+	defer CleanupTestApis(db)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/items/",
+			Method: http.MethodPut,
+			Body: CR{
+				"title":       "O'Reilly \"db\" `crud`",
+				"description": "quotes",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 3,
+				},
+			},
+		},
+		Case{
+			Path:  "/items/3",
+			Query: "select=title,description",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title":       "O'Reilly \"db\" `crud`",
+						"description": "quotes",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/",
+			Method: http.MethodPut,
+			Body: CR{
+				"title":       "C:\\temp\\' OR 1=1 --",
+				"description": "backslashes",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 4,
+				},
+			},
+		},
+		Case{
+			Path:  "/items/4",
+			Query: "select=title,description",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title":       "C:\\temp\\' OR 1=1 --",
+						"description": "backslashes",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/",
+			Method: http.MethodPut,
+			Body: CR{
+				"title":       "Рассказать 日本語 ✓",
+				"description": "unicode",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 5,
+				},
+			},
+		},
+		Case{
+			Path:  "/items/5",
+			Query: "select=title,description",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title":       "Рассказать 日本語 ✓",
+						"description": "unicode",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/",
+			Method: http.MethodPut,
+			Body: CR{
+				"title":       "a\x00b",
+				"description": "nul",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 6,
+				},
+			},
+		},
+		Case{
+			Path:  "/items/6",
+			Query: "select=title,description",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title":       "a\x00b",
+						"description": "nul",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/items/1",
+			Method: http.MethodPost,
+			Body: CR{
+				"title":   "it's \\'; DROP TABLE items; --",
+				"updated": "\x00",
+			},
+			Result: CR{
+				"response": CR{
+					"updated": 1,
+				},
+			},
+		},
+		Case{
+			Path:  "/items/1",
+			Query: "select=title,updated",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title":   "it's \\'; DROP TABLE items; --",
+						"updated": "\x00",
+					},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestValidation(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS products;`,
+		`CREATE TABLE products (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  name varchar(5) NOT NULL,
+  kind enum('a','b') NOT NULL,
+  price decimal(4,2) DEFAULT NULL,
+  qty tinyint(3) unsigned NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`DROP TABLE IF EXISTS schedules;`,
+		`CREATE TABLE schedules (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  title varchar(10) NOT NULL,
+  starts datetime NOT NULL,
+  day date NOT NULL,
+  at time NOT NULL,
+  meta json NOT NULL,
+  data varbinary(16) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS products, schedules;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/products/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"name":  "toolong",
+				"kind":  "c",
+				"price": 100,
+				"qty":   -1,
+			},
+			Result: CR{
+				"code": "invalid_fields",
+				"error": "field name have too long value, field kind have invalid value, " +
+					"field price have out of range value, field qty have out of range value",
+				"fields": []CR{
+					CR{"field": "name", "message": "too long value"},
+					CR{"field": "kind", "message": "invalid value"},
+					CR{"field": "price", "message": "out of range value"},
+					CR{"field": "qty", "message": "out of range value"},
+				},
+			},
+		},
+		Case{
+			Path:   "/products/",
+			Method: http.MethodPut,
+			Body: CR{
+				"name": "ok",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 1,
+				},
+			},
+		},
+		Case{
+			Path: "/products/1",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":    1,
+						"name":  "ok",
+						"kind":  "a",
+						"price": nil,
+						"qty":   0,
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/products/1",
+			Method: http.MethodPost,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"qty":  1.5,
+				"kind": nil,
+			},
+			Result: CR{
+				"code":  "invalid_fields",
+				"error": "field kind have invalid type, field qty have invalid type",
+				"fields": []CR{
+					CR{"field": "kind", "message": "invalid type"},
+					CR{"field": "qty", "message": "invalid type"},
+				},
+			},
+		},
+		Case{
+			Path:   "/schedules/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body:   CR{},
+			Result: CR{
+				"code": "invalid_fields",
+				"error": "field starts have missing value, field day have missing value, field at have missing value, " +
+					"field meta have missing value, field data have missing value",
+				"fields": []CR{
+					CR{"field": "starts", "message": "missing value"},
+					CR{"field": "day", "message": "missing value"},
+					CR{"field": "at", "message": "missing value"},
+					CR{"field": "meta", "message": "missing value"},
+					CR{"field": "data", "message": "missing value"},
+				},
+			},
+		},
+		Case{
+			Path:   "/schedules/",
+			Method: http.MethodPut,
+			Body: CR{
+				"starts": "2024-01-02T03:04:05Z",
+				"day":    "2024-01-02",
+				"at":     "03:04:05",
+				"meta":   CR{},
+				"data":   "AAEC",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 1,
+				},
+			},
+		},
+		Case{
+			Path: "/schedules/1",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":     1,
+						"title":  "",
+						"starts": "2024-01-02T03:04:05Z",
+						"day":    "2024-01-02",
+						"at":     "03:04:05",
+						"meta":   CR{},
+						"data":   "AAEC",
+					},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestTypes(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS typed;`,
+		`CREATE TABLE typed (
+  id bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  flag tinyint(1) DEFAULT NULL,
+  price decimal(10,2) DEFAULT NULL,
+  created datetime DEFAULT NULL,
+  day date DEFAULT NULL,
+  mask bit(8) DEFAULT NULL,
+  data varbinary(16) DEFAULT NULL,
+  meta json DEFAULT NULL,
+  state enum('new','used') DEFAULT NULL,
+  starts time DEFAULT NULL,
+  content blob DEFAULT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS typed;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/typed/",
+			Method: http.MethodPut,
+			Body: CR{
+				"flag":    true,
+				"price":   "12.34",
+				"created": "2024-01-02T03:04:05Z",
+				"day":     "2024-01-02",
+				"mask":    5,
+				"data":    "AAEC",
+				"meta":    CR{"tags": []string{"a", "b"}},
+				"state":   "used",
+				"starts":  "03:04:05",
+				"content": "AAEC",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 1,
+				},
+			},
+		},
+		Case{
+			Path: "/typed/1",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":      1,
+						"flag":    true,
+						"price":   "12.34",
+						"created": "2024-01-02T03:04:05Z",
+						"day":     "2024-01-02",
+						"mask":    5,
+						"data":    "AAEC",
+						"meta":    CR{"tags": []string{"a", "b"}},
+						"state":   "used",
+						"starts":  "03:04:05",
+						"content": "AAEC",
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/typed",
+			Query: "flag=eq.true&created=gte.2024-01-02T00:00:00Z&select=price&decimals=number",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"price": 12.34,
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/typed/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"flag":    "yes",
+				"created": "yesterday",
+				"data":    "not base64!",
+				"state":   "broken",
+			},
+			Result: CR{
+				"code":  "invalid_fields",
+				"error": "field flag have invalid type, field created have invalid value, field data have invalid value, field state have invalid value",
+				"fields": []CR{
+					CR{"field": "flag", "message": "invalid type"},
+					CR{"field": "created", "message": "invalid value"},
+					CR{"field": "data", "message": "invalid value"},
+					CR{"field": "state", "message": "invalid value"},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestBigInts(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS bigs;`,
+		`CREATE TABLE bigs (
+  id bigint(20) unsigned NOT NULL,
+  ref bigint(20) DEFAULT NULL,
+  flags bit(16) DEFAULT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS bigs;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/bigs/?bigints=string",
+			Method: http.MethodPut,
+			Body: CR{
+				"id":    uint64(9007199254740993),
+				"ref":   int64(-9007199254740993),
+				"flags": 3,
+			},
+			Result: CR{
+				"response": CR{
+					"id": "9007199254740993",
+				},
+			},
+		},
+		Case{
+			Path:  "/bigs/9007199254740993",
+			Query: "bigints=string",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":    "9007199254740993",
+						"ref":   "-9007199254740993",
+						"flags": 3,
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/bigs/ref/distinct",
+			Query: "bigints=string",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"value": "-9007199254740993", "count": 1},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/bigs",
+			Query: "ref=eq.-9007199254740993&select=id&bigints=string",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{
+							"id": "9007199254740993",
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/bigs/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"id": -1,
+			},
+			Result: CR{
+				"code":  "invalid_fields",
+				"error": "field id have out of range value",
+				"fields": []CR{
+					CR{"field": "id", "message": "out of range value"},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestBulkInsert(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS tags;`,
+		`CREATE TABLE tags (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  name varchar(10) NOT NULL,
+  color varchar(10) NOT NULL DEFAULT 'grey',
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS tags;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+	// three rows are sent as two statements
+	handler.BulkChunkSize = 2
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/tags/",
+			Method: http.MethodPut,
+			Body: []CR{
+				CR{"name": "go"},
+				CR{"name": "sql", "color": "blue"},
+				CR{"name": "http"},
+			},
+			Result: CR{
+				"response": CR{
+					"ids": []CR{
+						CR{"id": 1},
+						CR{"id": 2},
+						CR{"id": 3},
+					},
+				},
+			},
+		},
+		Case{
+			Path:  "/tags",
+			Query: "select=name,color",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"name": "go", "color": "grey"},
+						CR{"name": "sql", "color": "blue"},
+						CR{"name": "http", "color": "grey"},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/tags/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body: []CR{
+				CR{"name": "ok"},
+				CR{"name": "much too long"},
+			},
+			Result: CR{
+				"code":  "invalid_fields",
+				"error": "row 1 field name have too long value",
+				"fields": []CR{
+					CR{"row": 1, "field": "name", "message": "too long value"},
+				},
+			},
+		},
+		Case{
+			Path:   "/tags/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body:   []CR{},
+			Result: CR{
+				"code":  "bad_request",
+				"error": "no records to insert",
+			},
+		},
+		// nothing is inserted when a row is rejected
+		Case{
+			Path:  "/tags",
+			Query: "count=exact&limit=1",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"id": 1, "name": "go", "color": "grey"},
+					},
+					"total":    3,
+					"limit":    1,
+					"offset":   0,
+					"has_more": true,
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestFilteredWrites(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS drafts;`,
+		`CREATE TABLE drafts (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  status varchar(10) NOT NULL,
+  priority int(11) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO drafts (status, priority) VALUES
+  ('draft', 1), ('draft', 2), ('draft', 3), ('published', 4);`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS drafts;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+	handler.MaxAffectedRows = 2
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/drafts/",
+			Method: http.MethodDelete,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "filter is required",
+			},
+		},
+		Case{
+			Path:   "/drafts/?status=eq.draft",
+			Method: http.MethodPatch,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"status": "archived",
+			},
+			Result: CR{
+				"code":  "bad_request",
+				"error": "filter matches 3 rows, at most 2 can be changed at once",
+			},
+		},
+		Case{
+			Path:   "/drafts/?status=eq.draft&priority=lt.3&dry_run=1",
+			Method: http.MethodPatch,
+			Body: CR{
+				"status": "archived",
+			},
+			Result: CR{
+				"response": CR{
+					"updated": 2,
+					"dry_run": true,
+				},
+			},
+		},
+		Case{
+			Path:   "/drafts/?status=eq.draft&priority=lt.3",
+			Method: http.MethodPatch,
+			Body: CR{
+				"status": "archived",
+			},
+			Result: CR{
+				"response": CR{
+					"updated": 2,
+				},
+			},
+		},
+		Case{
+			Path:   "/drafts/?status=eq.archived&dry_run=true",
+			Method: http.MethodDelete,
+			Result: CR{
+				"response": CR{
+					"deleted": 2,
+					"dry_run": true,
+				},
+			},
+		},
+		Case{
+			Path:   "/drafts/?status=eq.archived",
+			Method: http.MethodDelete,
+			Result: CR{
+				"response": CR{
+					"deleted": 2,
+				},
+			},
+		},
+		Case{
+			Path:  "/drafts",
+			Query: "select=id,status",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"id": 3, "status": "draft"},
+						CR{"id": 4, "status": "published"},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/drafts/?status=eq.draft&limit=1",
+			Method: http.MethodDelete,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown parameter limit",
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestUpsert(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS contacts;`,
+		`CREATE TABLE contacts (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  email varchar(255) NOT NULL,
+  name varchar(255) NOT NULL,
+  phone varchar(255) DEFAULT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY email (email),
+  UNIQUE KEY phone (phone)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO contacts (email, name, phone) VALUES ('rvasily@example.com', 'Vasily', '123');`,
+		`DROP TABLE IF EXISTS accounts;`,
+		`CREATE TABLE accounts (
+  code varchar(10) NOT NULL,
+  email varchar(255) NOT NULL,
+  name varchar(255) NOT NULL,
+  PRIMARY KEY (code),
+  UNIQUE KEY email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO accounts (code, email, name) VALUES ('a', 'a@example.com', 'A');`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS contacts, accounts;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/contacts/?on_conflict=email",
+			Method: http.MethodPut,
+			Body: CR{
+				"email": "rvasily@example.com",
+				"name":  "Vasily Romanov",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 1,
+				},
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=email",
+			Method: http.MethodPut,
+			Body: CR{
+				"email": "new@example.com",
+				"name":  "New",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 2,
+				},
+			},
+		},
+		Case{
+			Path: "/contacts",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"id": 1, "email": "rvasily@example.com", "name": "Vasily Romanov", "phone": "123"},
+						CR{"id": 2, "email": "new@example.com", "name": "New", "phone": nil},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=id",
+			Method: http.MethodPut,
+			Body: CR{
+				"id":   2,
+				"name": "Renamed",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 2,
+				},
+			},
+		},
+		Case{
+			Path:  "/contacts/2",
+			Query: "select=name",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"name": "Renamed",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=email",
+			Method: http.MethodPut,
+			Status: http.StatusConflict,
+			Body: CR{
+				"email": "new@example.com",
+				"name":  "Thief",
+				"phone": "123",
+			},
+			Result: CR{
+				"code":   "duplicate_entry",
+				"error":  "duplicate entry",
+				"field":  "phone",
+				"detail": "unique key phone matches a row other than the on_conflict target",
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=email",
+			Method: http.MethodPut,
+			Status: http.StatusConflict,
+			Body: CR{
+				"email": "other@example.com",
+				"name":  "Thief",
+				"phone": "123",
+			},
+			Result: CR{
+				"code":   "duplicate_entry",
+				"error":  "duplicate entry",
+				"field":  "phone",
+				"detail": "unique key phone matches a row other than the on_conflict target",
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=email",
+			Method: http.MethodPut,
+			Body: CR{
+				"email": "rvasily@example.com",
+				"name":  "Vasily Romanov",
+				"phone": "123",
+			},
+			Result: CR{
+				"response": CR{
+					"id": 1,
+				},
+			},
+		},
+		Case{
+			Path: "/contacts",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"id": 1, "email": "rvasily@example.com", "name": "Vasily Romanov", "phone": "123"},
+						CR{"id": 2, "email": "new@example.com", "name": "Renamed", "phone": nil},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=name",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"name": "Vasily",
+			},
+			Result: CR{
+				"code":  "bad_request",
+				"error": "no unique index on name",
+			},
+		},
+		Case{
+			Path:   "/contacts/?on_conflict=email",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body: CR{
+				"name": "Vasily",
+			},
+			Result: CR{
+				"code":  "invalid_fields",
+				"error": "field email have missing value",
+				"fields": []CR{
+					CR{"field": "email", "message": "missing value"},
+				},
+			},
+		},
+		Case{
+			Path:   "/contacts/",
+			Method: http.MethodPut,
+			Status: http.StatusConflict,
+			Body: CR{
+				"email": "new@example.com",
+				"name":  "Duplicate",
+			},
+			Result: CR{
+				"code":   "duplicate_entry",
+				"error":  "duplicate entry",
+				"field":  "email",
+				"detail": "Duplicate entry 'new@example.com' for key 'email'",
+			},
+		},
+		Case{
+			Path:   "/contacts/",
+			Method: http.MethodPut,
+			Status: http.StatusBadRequest,
+			Body:   "not an object",
+			Result: CR{
+				"code":   "invalid_body",
+				"error":  "invalid json body",
+				"detail": "json: cannot unmarshal string into Go value of type map[string]interface {}",
+			},
+		},
+		// the primary key of the matched row is kept, like on POST /accounts/a
+		Case{
+			Path:   "/accounts/?on_conflict=email",
+			Method: http.MethodPut,
+			Body: CR{
+				"code":  "b",
+				"email": "a@example.com",
+				"name":  "Renamed",
+			},
+			Result: CR{
+				"response": CR{
+					"code": "a",
+				},
+			},
+		},
+		Case{
+			Path: "/accounts",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"code": "a", "email": "a@example.com", "name": "Renamed"},
+					},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestBatch(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS notes, notebooks;`,
+		`CREATE TABLE notebooks (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  title varchar(255) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`CREATE TABLE notes (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  notebook_id int(11) NOT NULL,
+  body text NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (notebook_id) REFERENCES notebooks (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS notes, notebooks;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:   "/_batch",
+			Method: http.MethodPost,
+			Body: []CR{
+				CR{"op": "create", "table": "notebooks", "body": CR{"title": "work"}},
+				CR{"op": "create", "table": "notes", "body": CR{"notebook_id": CR{"$ref": "0.id"}, "body": "first"}},
+				CR{"op": "create", "table": "notes", "body": CR{"notebook_id": CR{"$ref": "0.id"}, "body": "second"}},
+				CR{"op": "update", "table": "notes", "id": CR{"$ref": "1.id"}, "body": CR{"body": "updated"}},
+				CR{"op": "delete", "table": "notes", "id": CR{"$ref": "2.id"}},
+			},
+			Result: CR{
+				"response": CR{
+					"results": []CR{
+						CR{"id": 1},
+						CR{"id": 1},
+						CR{"id": 2},
+						CR{"updated": 1},
+						CR{"deleted": 1},
+					},
+				},
+			},
+		},
+		// strings that look like references are plain values
+		Case{
+			Path:   "/_batch",
+			Method: http.MethodPost,
+			Body: []CR{
+				CR{"op": "create", "table": "notes", "body": CR{"notebook_id": 1, "body": "$1.50"}},
+				CR{"op": "create", "table": "notes", "body": CR{"notebook_id": 1, "body": "$0.id"}},
+			},
+			Result: CR{
+				"response": CR{
+					"results": []CR{
+						CR{"id": 3},
+						CR{"id": 4},
+					},
+				},
+			},
+		},
+		Case{
+			Path: "/notes",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"id": 1, "notebook_id": 1, "body": "updated"},
+						CR{"id": 3, "notebook_id": 1, "body": "$1.50"},
+						CR{"id": 4, "notebook_id": 1, "body": "$0.id"},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/_batch",
+			Method: http.MethodPost,
+			Status: http.StatusBadRequest,
+			Body: []CR{
+				CR{"op": "create", "table": "notebooks", "body": CR{"title": "home"}},
+				CR{"op": "create", "table": "notes", "body": CR{"notebook_id": CR{"$ref": "0.id"}, "body": 42}},
+			},
+			Result: CR{
+				"code":      "invalid_fields",
+				"error":     "operation 1: field body have invalid type",
+				"operation": 1,
+				"fields": []CR{
+					CR{"field": "body", "message": "invalid type"},
+				},
+			},
+		},
+		Case{
+			Path:   "/_batch",
+			Method: http.MethodPost,
+			Status: http.StatusBadRequest,
+			Body: []CR{
+				CR{"op": "delete", "table": "notes", "id": CR{"$ref": "1.id"}},
+			},
+			Result: CR{
+				"code":      "bad_request",
+				"error":     "operation 0: invalid reference 1.id",
+				"operation": 0,
+			},
+		},
+		// the rejected batch did not create a notebook
+		Case{
+			Path:  "/notebooks",
+			Query: "select=title",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"title": "work"},
+					},
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestETags(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS pages;`,
+		`CREATE TABLE pages (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  title varchar(255) NOT NULL,
+  version int(11) NOT NULL DEFAULT 1,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO pages (id, title) VALUES (1, 'home'), (2, 'about');`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS pages;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+	handler.VersionColumns["pages"] = "version"
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:            "/pages/1",
+			Query:           "select=title",
+			ResponseHeaders: map[string]string{"ETag": `"1"`},
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title": "home",
+					},
+				},
+			},
+		},
+		Case{
+			Path:    "/pages/1",
+			Method:  http.MethodPost,
+			Headers: map[string]string{"If-Match": `"1"`},
+			Body: CR{
+				"title": "main",
+			},
+			Result: CR{
+				"response": CR{
+					"updated": 1,
+				},
+			},
+		},
+		Case{
+			Path:    "/pages/1",
+			Method:  http.MethodPost,
+			Status:  http.StatusPreconditionFailed,
+			Headers: map[string]string{"If-Match": `"1"`},
+			Body: CR{
+				"title": "index",
+			},
+			Result: CR{
+				"code":  "precondition_failed",
+				"error": "precondition failed",
+			},
+		},
+		Case{
+			Path:    "/pages/1",
+			Method:  http.MethodPost,
+			Status:  http.StatusBadRequest,
+			Headers: map[string]string{"If-Match": `"2"`},
+			Body: CR{
+				"title":   "index",
+				"version": 1,
+			},
+			Result: CR{
+				"code":  "invalid_fields",
+				"error": "field version have read only value",
+				"fields": []CR{
+					CR{"field": "version", "message": "read only value"},
+				},
+			},
+		},
+		Case{
+			Path:            "/pages/1",
+			ResponseHeaders: map[string]string{"ETag": `"2"`},
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":      1,
+						"title":   "main",
+						"version": 2,
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/pages/1",
+			Method: http.MethodPost,
+			Body: CR{
+				"title": "main",
+			},
+			Result: CR{
+				"response": CR{
+					"updated": 1,
+				},
+			},
+		},
+		Case{
+			Path:            "/pages/1",
+			Query:           "select=title",
+			ResponseHeaders: map[string]string{"ETag": `"3"`},
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"title": "main",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/pages/9",
+			Method: http.MethodPost,
+			Status: http.StatusNotFound,
+			Body: CR{
+				"title": "missing",
+			},
+			Result: CR{
+				"code":  "not_found",
+				"error": "record not found",
+			},
+		},
+		Case{
+			Path:    "/pages/2",
+			Method:  http.MethodDelete,
+			Status:  http.StatusPreconditionFailed,
+			Headers: map[string]string{"If-Match": `"5", "7"`},
+			Result: CR{
+				"code":  "precondition_failed",
+				"error": "precondition failed",
+			},
+		},
+		Case{
+			Path:    "/pages/2",
+			Method:  http.MethodDelete,
+			Headers: map[string]string{"If-Match": `"5", "1"`},
+			Result: CR{
+				"response": CR{
+					"deleted": 1,
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestRouting(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS routes, slugs;`,
+		`CREATE TABLE routes (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  name varchar(255) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO routes (id, name) VALUES (1, 'home');`,
+		`CREATE TABLE slugs (
+  slug varchar(64) NOT NULL,
+  title varchar(255) NOT NULL,
+  PRIMARY KEY (slug)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO slugs (slug, title) VALUES ('search', 'Search');`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS routes, slugs;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path:            "/",
+			Method:          http.MethodPost,
+			Status:          http.StatusMethodNotAllowed,
+			ResponseHeaders: map[string]string{"Allow": "GET, HEAD, OPTIONS"},
+			Result: CR{
+				"code":  "method_not_allowed",
+				"error": "method not allowed",
+			},
+		},
+		Case{
+			Path:            "/routes/search",
+			Method:          http.MethodPost,
+			Status:          http.StatusMethodNotAllowed,
+			ResponseHeaders: map[string]string{"Allow": "GET, HEAD, OPTIONS"},
+			Result: CR{
+				"code":  "method_not_allowed",
+				"error": "method not allowed",
+			},
+		},
+		Case{
+			Path:   "/routes/1/x",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"code":  "not_found",
+				"error": "not found",
+			},
+		},
+		Case{
+			Path:   "/routes/1",
+			Query:  "limit=%zz",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "invalid query string",
+			},
+		},
+		Case{
+			Path:   "/routes/1",
+			Query:  "limit=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown parameter limit",
+			},
+		},
+		Case{
+			Path:            "/routes/1",
+			Method:          http.MethodOptions,
+			Status:          http.StatusNoContent,
+			ResponseHeaders: map[string]string{"Allow": "GET, HEAD, POST, DELETE, OPTIONS"},
+		},
+		Case{
+			Path:            "/routes/1",
+			Method:          http.MethodHead,
+			ResponseHeaders: map[string]string{"Content-Type": "application/json"},
+		},
+		Case{
+			Path: "/routes/1/",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":   1,
+						"name": "home",
+					},
+				},
+			},
+		},
+		Case{
+			Path: "/slugs/%73earch",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"slug":  "search",
+						"title": "Search",
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/slugs/%73earch",
+			Method: http.MethodDelete,
+			Result: CR{
+				"response": CR{
+					"deleted": 1,
+				},
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestSchemaReload(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS migrated;`)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS migrated;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	qs := []string{
+		`CREATE TABLE migrated (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  title varchar(255) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`INSERT INTO migrated (id, title) VALUES (1, 'first');`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	runCases(t, ts, db, []Case{
+		Case{
+			Path:   "/migrated",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"code":  "not_found",
+				"error": "unknown table",
+			},
+		},
+		Case{
+			Path:   "/_schema/reload",
+			Method: http.MethodPost,
+			Result: CR{
+				"response": CR{
+					"changes": []string{"table migrated added"},
+				},
+			},
+		},
+		Case{
+			Path: "/migrated",
+			Result: CR{
+				"response": CR{
+					"records": []CR{
+						CR{"id": 1, "title": "first"},
+					},
+				},
+			},
+		},
+	})
+
+	_, err = db.Exec(`ALTER TABLE migrated ADD COLUMN done tinyint(1) NOT NULL DEFAULT 0, MODIFY title varchar(100) NOT NULL;`)
+	if err != nil {
+		panic(err)
+	}
+
+	runCases(t, ts, db, []Case{
+		Case{
+			Path:   "/_schema/reload",
+			Method: http.MethodPost,
+			Result: CR{
+				"response": CR{
+					"changes": []string{"column migrated.title changed", "column migrated.done added"},
+				},
+			},
+		},
+		Case{
+			Path: "/migrated/1",
+			Result: CR{
+				"response": CR{
+					"record": CR{
+						"id":    1,
+						"title": "first",
+						"done":  false,
+					},
+				},
+			},
+		},
+	})
+
+	_, err = db.Exec(`DROP TABLE migrated;`)
+	if err != nil {
+		panic(err)
+	}
+
+	// the driver error only goes to the log
+	runCases(t, ts, db, []Case{
+		Case{
+			Path:   "/migrated",
+			Status: http.StatusInternalServerError,
+			Result: CR{
+				"code":  "internal_error",
+				"error": "internal error",
+			},
+		},
+		Case{
+			Path:   "/_schema/reload",
+			Method: http.MethodPost,
+			Result: CR{
+				"response": CR{
+					"changes": []string{"table migrated removed"},
+				},
+			},
+		},
+	})
+	if _, exists := handler.Schema().Data["migrated"]; exists {
+		t.Errorf("expected the reloaded schema without the migrated table")
+	}
+}
+
+func TestSchemaPolling(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS polled;`)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS polled;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+	stop := handler.PollSchema(10 * time.Millisecond)
+	defer stop()
+
+	_, err = db.Exec(`CREATE TABLE polled (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`)
+	if err != nil {
+		panic(err)
+	}
+
+	// the table shows up without a reload request once the poller has run
+	status := 0
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, err := client.Get(ts.URL + "/polled")
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status == http.StatusOK {
+			break
+		}
+	}
+	if status != http.StatusOK {
+		t.Errorf("expected polled table to be served, got status %d", status)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS books, shelves;`,
+		`CREATE TABLE shelves (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+		`CREATE TABLE books (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  shelf_id int(11) DEFAULT NULL,
+  title varchar(50) NOT NULL DEFAULT 'untitled' COMMENT 'cover title',
+  notes json DEFAULT NULL,
+  PRIMARY KEY (id),
+  KEY shelf (shelf_id),
+  CONSTRAINT fk_shelf FOREIGN KEY (shelf_id) REFERENCES shelves (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS books, shelves;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	cases := []Case{
+		Case{
+			Path: "/books/_schema",
+			Result: CR{
+				"response": CR{
+					"table": CR{
+						"name": "books",
+						"columns": []CR{
+							CR{
+								"name":      "id",
+								"type":      "int(11)",
+								"nullable":  false,
+								"default":   nil,
+								"key":       "PRI",
+								"extra":     "auto_increment",
+								"comment":   "",
+								"collation": nil,
+							},
+							CR{
+								"name":      "shelf_id",
+								"type":      "int(11)",
+								"nullable":  true,
+								"default":   "NULL",
+								"key":       "MUL",
+								"extra":     "",
+								"comment":   "",
+								"collation": nil,
+							},
+							CR{
+								"name":      "title",
+								"type":      "varchar(50)",
+								"nullable":  false,
+								"default":   "'untitled'",
+								"key":       "",
+								"extra":     "",
+								"comment":   "cover title",
+								"collation": "utf8_general_ci",
+							},
+							// MariaDB stores json as longtext, the reported type is kept
+							CR{
+								"name":      "notes",
+								"type":      "longtext",
+								"nullable":  true,
+								"default":   "NULL",
+								"key":       "",
+								"extra":     "",
+								"comment":   "",
+								"collation": "utf8mb4_bin",
+							},
+						},
+						"primary_key": []string{"id"},
+						"indexes": []CR{
+							CR{"name": "PRIMARY", "columns": []string{"id"}, "unique": true, "type": "BTREE"},
+							CR{"name": "shelf", "columns": []string{"shelf_id"}, "unique": false, "type": "BTREE"},
+						},
+						"foreign_keys": []CR{
+							CR{
+								"name":               "fk_shelf",
+								"table":              "books",
+								"columns":            []string{"shelf_id"},
+								"referenced_table":   "shelves",
+								"referenced_columns": []string{"id"},
+							},
+						},
+					},
+				},
+			},
+		},
+		Case{
+			Path:   "/unknown/_schema",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"code":  "not_found",
+				"error": "unknown table",
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func TestOpenAPI(t *testing.T) {
+	db, err := sql.Open("mysql", DSN)
+	err = db.Ping()
+	if err != nil {
+		panic(err)
+	}
+
+	qs := []string{
+		`DROP TABLE IF EXISTS gadgets;`,
+		`CREATE TABLE gadgets (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  name varchar(20) NOT NULL,
+  price decimal(8,2) DEFAULT NULL,
+  state enum('new','used') NOT NULL DEFAULT 'new',
+  stock int(10) unsigned NOT NULL DEFAULT 0,
+  serial bigint(20) unsigned NOT NULL DEFAULT 0,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;`,
+	}
+	for _, q := range qs {
+		_, err := db.Exec(q)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer db.Exec(`DROP TABLE IF EXISTS gadgets;`)
+
+	handler, err := NewDbExplorer(db)
+	if err != nil {
+		panic(err)
+	}
+
+	ts := httptest.NewServer(handler)
+
+	resp, err := client.Get(ts.URL + "/_openapi.json")
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	defer resp.Body.Close()
+	document := struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&document)
+	if err != nil {
+		t.Fatalf("cant unpack document: %v", err)
+	}
+
+	if document.OpenAPI != "3.1.0" {
+		t.Errorf("expected openapi 3.1.0, got %s", document.OpenAPI)
+	}
+	for path, methods := range map[string][]string{
+		"/gadgets":      {"get", "put", "patch", "delete"},
+		"/gadgets/{id}": {"get", "post", "delete"},
+	} {
+		for _, method := range methods {
+			if _, exists := document.Paths[path][method]; !exists {
+				t.Errorf("expected %s %s in paths", method, path)
+			}
+		}
+	}
+
+	schemas := map[string]CR{
+		"Table_gadgets": CR{
+			"type": "object",
+			"properties": CR{
+				"id":     CR{"type": "integer", "format": "int32"},
+				"name":   CR{"type": "string", "maxLength": 20},
+				"price":  CR{"type": []string{"string", "null"}, "format": "decimal"},
+				"state":  CR{"type": "string", "enum": []string{"new", "used"}},
+				"stock":  CR{"type": "integer", "format": "int64", "minimum": 0},
+				"serial": CR{"type": "integer", "minimum": 0},
+			},
+		},
+		"TableCreate_gadgets": CR{
+			"type": "object",
+			"properties": CR{
+				"name":   CR{"type": "string", "maxLength": 20},
+				"price":  CR{"type": []string{"string", "null"}, "format": "decimal"},
+				"state":  CR{"type": "string", "enum": []string{"new", "used"}},
+				"stock":  CR{"type": "integer", "format": "int64", "minimum": 0},
+				"serial": CR{"type": "integer", "minimum": 0},
+			},
+		},
+	}
+	for name, schema := range schemas {
+		var expected interface{}
+		data, _ := json.Marshal(schema)
+		json.Unmarshal(data, &expected)
+		if !reflect.DeepEqual(document.Components.Schemas[name], expected) {
+			t.Errorf("schema %s: results not match\nGot: %#v\nExpected: %#v", name, document.Components.Schemas[name], expected)
+		}
+	}
+
+	// PUT takes a record or a list of them and upserts with on_conflict
+	put, _ := document.Paths["/gadgets"]["put"].(map[string]interface{})
+	parts := map[string]interface{}{
+		"body": CR{"oneOf": []CR{
+			CR{"$ref": "#/components/schemas/TableCreate_gadgets"},
+			CR{"type": "array", "items": CR{"$ref": "#/components/schemas/TableCreate_gadgets"}},
+		}},
+		"response": CR{"oneOf": []CR{
+			CR{
+				"type":       "object",
+				"properties": CR{"id": CR{"type": "integer", "format": "int32"}},
+				"required":   []string{"id"},
+			},
+			CR{
+				"type": "object",
+				"properties": CR{"ids": CR{
+					"type":  "array",
+					"items": CR{"type": "object", "properties": CR{"id": CR{"type": "integer", "format": "int32"}}},
+				}},
+				"required": []string{"ids"},
+			},
+		}},
+		"on_conflict": "on_conflict",
+	}
+	got := map[string]interface{}{}
+	if body, ok := put["requestBody"].(map[string]interface{}); ok {
+		got["body"] = body["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"]
+	}
+	if responses, ok := put["responses"].(map[string]interface{}); ok {
+		content := responses["200"].(map[string]interface{})["content"].(map[string]interface{})
+		schema := content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+		got["response"] = schema["properties"].(map[string]interface{})["response"]
+	}
+	if parameters, ok := put["parameters"].([]interface{}); ok && len(parameters) > 0 {
+		got["on_conflict"] = parameters[0].(map[string]interface{})["name"]
+	}
+	var expectedParts interface{}
+	data, _ := json.Marshal(parts)
+	json.Unmarshal(data, &expectedParts)
+	if !reflect.DeepEqual(interface{}(got), expectedParts) {
+		t.Errorf("put: results not match\nGot: %#v\nExpected: %#v", got, expectedParts)
+	}
+
+	cases := []Case{
+		Case{
+			Path:   "/_openapi.json",
+			Query:  "limit=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"code":  "bad_request",
+				"error": "unknown parameter limit",
+			},
+		},
+	}
+
+	runCases(t, ts, db, cases)
+}
+
+func runCases(t *testing.T, ts *httptest.Server, db *sql.DB, cases []Case) {
+	for idx, item := range cases {
+		var (
+			err      error
+			result   interface{}
+			expected interface{}
+			req      *http.Request
+		)
+
+		caseName := fmt.Sprintf("case %d: [%s] %s %s", idx, item.Method, item.Path, item.Query)
+
+		// если у вас случилась это ошибка - значит вы не делаете где-то rows.Close и у вас текут соединения с базой
+		// если такое случилось на первом тесте - значит вы не закрываете коннект где-то при иницаилизации в NewDbExplorer
+		if db.Stats().OpenConnections != 1 {
+			t.Fatalf("[%s] you have %d open connections, must be 1", caseName, db.Stats().OpenConnections)
+		}
+
+		if item.Method == "" || item.Method == http.MethodGet {
+			req, err = http.NewRequest(item.Method, ts.URL+item.Path+"?"+item.Query, nil)
+		} else {
+			data, err := json.Marshal(item.Body)
+			if err != nil {
+				panic(err)
+			}
+			reqBody := bytes.NewReader(data)
+			req, err = http.NewRequest(item.Method, ts.URL+item.Path, reqBody)
+			req.Header.Add("Content-Type", "application/json")
+		}
+		for name, value := range item.Headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("[%s] request error: %v", caseName, err)
+			continue
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+
+		// fmt.Printf("[%s] body: %s\n", caseName, string(body))
+		if item.Status == 0 {
+			item.Status = http.StatusOK
+		}
+
+		if resp.StatusCode != item.Status {
+			t.Fatalf("[%s] expected http status %v, got %v", caseName, item.Status, resp.StatusCode)
+			continue
+		}
+
+		for name, value := range item.ResponseHeaders {
+			if resp.Header.Get(name) != value {
+				t.Fatalf("[%s] expected header %s %q, got %q", caseName, name, value, resp.Header.Get(name))
+			}
+		}
+
+		// на HEAD и OPTIONS ответ приходит без тела
+		if item.Result == nil && len(body) == 0 {
 			continue
 		}
 
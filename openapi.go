@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// buildOpenAPI describes the endpoints of every table, the document follows
+// the schema loaded at the time of the request
+func buildOpenAPI(d *DbExplorer) map[string]interface{} {
+	text := map[string]interface{}{"type": "string"}
+	integer := map[string]interface{}{"type": "integer"}
+	boolean := map[string]interface{}{"type": "boolean"}
+	paths := make(map[string]interface{})
+	schemas := map[string]interface{}{
+		"Error": errorSchema(),
+	}
+
+	for _, table := range d.schema.TableNames {
+		tableData := d.schema.Data[table]
+		primaryKey := getPrimaryKey(table, d)
+
+		create := make([]FieldMetaData, 0, len(tableData))
+		update := make([]FieldMetaData, 0, len(tableData))
+		for _, datum := range tableData {
+			if datum.Extra.String != "auto_increment" {
+				create = append(create, datum)
+			}
+			if datum.Key.String != "PRI" {
+				update = append(update, datum)
+			}
+		}
+		schemas[componentName("Table", table)] = objectSchema(tableData)
+		schemas[componentName("TableCreate", table)] = objectSchema(create)
+		schemas[componentName("TableUpdate", table)] = objectSchema(update)
+
+		filters := make([]interface{}, len(tableData))
+		for i, datum := range tableData {
+			filter := queryParameter(datum.Field, text)
+			filter["description"] = "filter like eq.value, in.(a,b) or is.null"
+			filters[i] = filter
+		}
+		listParameters := append([]interface{}{
+			queryParameter("limit", map[string]interface{}{"type": "integer", "minimum": 0}),
+			queryParameter("offset", map[string]interface{}{"type": "integer", "minimum": 0}),
+			queryParameter("after", text),
+			queryParameter("order", text),
+			queryParameter("select", text),
+			queryParameter("count", map[string]interface{}{"enum": []string{"exact", "estimated"}}),
+			queryParameter("decimals", map[string]interface{}{"enum": []string{"string", "number"}}),
+			queryParameter("bigints", map[string]interface{}{"enum": []string{"number", "string"}}),
+		}, filters...)
+		writeParameters := append([]interface{}{
+			queryParameter("dry_run", map[string]interface{}{"type": "boolean"}),
+		}, filters...)
+
+		// PUT takes a record or a list of records for a bulk insert,
+		// on_conflict only works with a single record
+		onConflict := queryParameter("on_conflict", text)
+		onConflict["description"] = "columns of a unique index, separated by commas, to update the matching record"
+		createBody := map[string]interface{}{"oneOf": []interface{}{
+			schemaRef(componentName("TableCreate", table)),
+			map[string]interface{}{"type": "array", "items": schemaRef(componentName("TableCreate", table))},
+		}}
+		// the required keys tell the two responses apart
+		created := objectSchema(primaryKey)
+		created["required"] = extractFieldNames(primaryKey)
+		createdRows := properties(map[string]interface{}{
+			"ids": map[string]interface{}{"type": "array", "items": objectSchema(primaryKey)},
+		})
+		createdRows["required"] = []string{"ids"}
+		createResponse := map[string]interface{}{"oneOf": []interface{}{created, createdRows}}
+
+		paths["/"+url.PathEscape(table)] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": "list_" + table,
+				"parameters":  listParameters,
+				"responses": responses(properties(map[string]interface{}{
+					"records": map[string]interface{}{"type": "array", "items": schemaRef(componentName("Table", table))},
+				})),
+			},
+			"put": map[string]interface{}{
+				"operationId": "create_" + table,
+				"parameters": []interface{}{
+					onConflict,
+					queryParameter("decimals", map[string]interface{}{"enum": []string{"string", "number"}}),
+					queryParameter("bigints", map[string]interface{}{"enum": []string{"number", "string"}}),
+				},
+				"requestBody": requestBody(createBody),
+				"responses":   responses(createResponse),
+			},
+			"patch": map[string]interface{}{
+				"operationId": "updateWhere_" + table,
+				"parameters":  writeParameters,
+				"requestBody": requestBody(schemaRef(componentName("TableUpdate", table))),
+				"responses":   responses(properties(map[string]interface{}{"updated": integer, "dry_run": boolean})),
+			},
+			"delete": map[string]interface{}{
+				"operationId": "deleteWhere_" + table,
+				"parameters":  writeParameters,
+				"responses":   responses(properties(map[string]interface{}{"deleted": integer, "dry_run": boolean})),
+			},
+		}
+
+		if len(primaryKey) == 0 {
+			continue
+		}
+		id := map[string]interface{}{
+			"name":        "id",
+			"in":          "path",
+			"required":    true,
+			"description": "primary key, values of a composite key are separated by commas",
+			"schema":      text,
+		}
+		paths["/"+url.PathEscape(table)+"/{id}"] = map[string]interface{}{
+			"parameters": []interface{}{id},
+			"get": map[string]interface{}{
+				"operationId": "get_" + table,
+				"parameters": []interface{}{
+					queryParameter("select", text),
+					queryParameter("embed", text),
+					queryParameter("decimals", map[string]interface{}{"enum": []string{"string", "number"}}),
+					queryParameter("bigints", map[string]interface{}{"enum": []string{"number", "string"}}),
+				},
+				"responses": responses(properties(map[string]interface{}{"record": schemaRef(componentName("Table", table))})),
+			},
+			"post": map[string]interface{}{
+				"operationId": "update_" + table,
+				"requestBody": requestBody(schemaRef(componentName("TableUpdate", table))),
+				"responses":   responses(properties(map[string]interface{}{"updated": integer})),
+			},
+			"delete": map[string]interface{}{
+				"operationId": "delete_" + table,
+				"responses":   responses(properties(map[string]interface{}{"deleted": integer})),
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "db_explorer",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// columnSchema follows the values written by convertValue with the default format
+func columnSchema(datum FieldMetaData) map[string]interface{} {
+	schema := make(map[string]interface{})
+	kind := fieldKind(datum)
+	switch kind {
+	case "bool":
+		schema["type"] = "boolean"
+	case "int", "uint", "bit":
+		schema["type"] = "integer"
+		// unsigned 32-bit values do not fit an int32, unsigned 64-bit ones and
+		// bit values up to 2^64-1 do not fit any format
+		bits := integerBits(datum.Type)
+		switch {
+		case kind == "bit", kind == "uint" && bits == 64:
+		case bits == 64, kind == "uint" && bits == 32:
+			schema["format"] = "int64"
+		default:
+			schema["format"] = "int32"
+		}
+		if kind != "int" {
+			schema["minimum"] = 0
+		}
+	case "float":
+		schema["type"] = "number"
+	case "decimal":
+		schema["type"] = "string"
+		schema["format"] = "decimal"
+	case "datetime":
+		schema["type"] = "string"
+		schema["format"] = "date-time"
+	case "date":
+		schema["type"] = "string"
+		schema["format"] = "date"
+	case "time":
+		schema["type"] = "string"
+	case "json":
+	case "binary":
+		schema["type"] = "string"
+		schema["contentEncoding"] = "base64"
+	default:
+		schema["type"] = "string"
+		switch baseType(datum.Type) {
+		case "char", "varchar":
+			if params := typeParams(datum.Type); len(params) == 1 {
+				if length, err := strconv.Atoi(params[0]); err == nil {
+					schema["maxLength"] = length
+				}
+			}
+		case "enum":
+			values := make([]interface{}, 0)
+			for _, value := range enumValues(datum.Type) {
+				values = append(values, value)
+			}
+			schema["enum"] = values
+		}
+	}
+
+	if datum.Null.String == "YES" {
+		if kind == "json" {
+			return schema
+		}
+		if values, exists := schema["enum"]; exists {
+			schema["enum"] = append(values.([]interface{}), nil)
+		}
+		schema["type"] = []interface{}{schema["type"], "null"}
+	}
+	if datum.Comment.String != "" {
+		schema["description"] = datum.Comment.String
+	}
+	return schema
+}
+
+func objectSchema(fields []FieldMetaData) map[string]interface{} {
+	columns := make(map[string]interface{}, len(fields))
+	for _, datum := range fields {
+		columns[datum.Field] = columnSchema(datum)
+	}
+	return properties(columns)
+}
+
+func properties(properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func errorSchema() map[string]interface{} {
+	text := map[string]interface{}{"type": "string"}
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "error"},
+		"properties": map[string]interface{}{
+			"code":      text,
+			"error":     text,
+			"field":     text,
+			"detail":    text,
+			"operation": map[string]interface{}{"type": "integer"},
+			"fields": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"row":     map[string]interface{}{"type": "integer"},
+						"field":   text,
+						"message": text,
+					},
+				},
+			},
+		},
+	}
+}
+
+// componentName prefixes the table so it can not collide with Error or the
+// schemas of another table. Characters not allowed in component names, and the
+// dash used to encode them, are written as -XX.
+func componentName(prefix string, table string) string {
+	var name strings.Builder
+	name.WriteString(prefix + "_")
+	for i := 0; i < len(table); i++ {
+		c := table[i]
+		if c == '_' || c == '.' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			name.WriteByte(c)
+		} else {
+			fmt.Fprintf(&name, "-%02X", c)
+		}
+	}
+	return name.String()
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func queryParameter(name string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"in":     "query",
+		"schema": schema,
+	}
+}
+
+func requestBody(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// successful responses are wrapped into {"response": ...} by writeResponse
+func responses(response map[string]interface{}) map[string]interface{} {
+	errorContent := map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schemaRef("Error")},
+	}
+	return map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "success",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"response": response},
+					},
+				},
+			},
+		},
+		"default": map[string]interface{}{
+			"description": "error",
+			"content":     errorContent,
+		},
+	}
+}
+
+func handleOpenAPI(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	marshal, err := json.Marshal(buildOpenAPI(d))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(marshal)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OrderBy struct {
+	Field      FieldMetaData
+	Descending bool
+	Nulls      string
+}
+
+func parseOrder(tableData []FieldMetaData, order string) ([]OrderBy, error) {
+	result := make([]OrderBy, 0)
+	if order == "" {
+		return result, nil
+	}
+
+	for _, term := range strings.Split(order, ",") {
+		parts := strings.Split(term, ".")
+		datum := findField(tableData, parts[0])
+		if datum == nil {
+			return nil, fmt.Errorf("unknown field %s", parts[0])
+		}
+		orderBy := OrderBy{Field: *datum}
+		for _, modifier := range parts[1:] {
+			switch modifier {
+			case "asc":
+				orderBy.Descending = false
+			case "desc":
+				orderBy.Descending = true
+			case "nullsfirst":
+				orderBy.Nulls = "first"
+			case "nullslast":
+				orderBy.Nulls = "last"
+			default:
+				return nil, fmt.Errorf("invalid order %s for field %s", modifier, datum.Field)
+			}
+		}
+		result = append(result, orderBy)
+	}
+	return result, nil
+}
+
+func withPrimaryKeyOrder(order []OrderBy, primaryKey []FieldMetaData) []OrderBy {
+	for _, datum := range primaryKey {
+		ordered := false
+		for _, orderBy := range order {
+			if orderBy.Field.Field == datum.Field {
+				ordered = true
+				break
+			}
+		}
+		if !ordered {
+			order = append(order, OrderBy{Field: datum})
+		}
+	}
+	return order
+}
+
+func buildOrderClause(order []OrderBy) string {
+	if len(order) == 0 {
+		return ""
+	}
+
+	terms := make([]string, 0, len(order))
+	for _, orderBy := range order {
+		column := fmt.Sprintf("`%s`", escapeIdentifier(orderBy.Field.Field))
+		// MySQL has no NULLS FIRST/LAST, nulls sort as the smallest value
+		switch orderBy.Nulls {
+		case "first":
+			terms = append(terms, column+" IS NULL DESC")
+		case "last":
+			terms = append(terms, column+" IS NULL ASC")
+		}
+		if orderBy.Descending {
+			terms = append(terms, column+" DESC")
+		} else {
+			terms = append(terms, column+" ASC")
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// RouteContext holds the parts of a matched request, Key is the
+// still escaped {key} path segment
+type RouteContext struct {
+	Table string
+	Key   string
+	Query url.Values
+}
+
+type RouteHandler func(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error
+
+// Params lists the accepted query parameters, nil leaves the check
+// to handlers that take filters on columns
+type Route struct {
+	Pattern string
+	Method  string
+	Params  []string
+	Handle  RouteHandler
+}
+
+// literal patterns go before the ones with variables at the same position,
+// the first pattern matching the path decides which methods are allowed.
+// Segments are compared before unescaping, so a record whose key is one of the
+// reserved names search, aggregate or _schema is reached with an escaped
+// character in the key, like /items/%73earch.
+func newRoutes() []Route {
+	format := []string{"decimals", "bigints"}
+	return []Route{
+		{"/", http.MethodGet, []string{}, handleTables},
+		{"/_batch", http.MethodPost, format, handleBatch},
+		{"/_schema", http.MethodGet, []string{}, handleSchema},
+		{"/_schema/reload", http.MethodPost, []string{}, handleReloadSchema},
+		{"/_openapi.json", http.MethodGet, []string{}, handleOpenAPI},
+		{"/{table}", http.MethodGet, nil, handleList},
+		{"/{table}", http.MethodPut, append([]string{"on_conflict"}, format...), handleCreate},
+		{"/{table}", http.MethodPatch, nil, handleUpdateRows},
+		{"/{table}", http.MethodDelete, nil, handleDeleteRows},
+		{"/{table}/search", http.MethodGet, nil, handleSearch},
+		{"/{table}/aggregate", http.MethodGet, nil, handleAggregate},
+		{"/{table}/_schema", http.MethodGet, []string{}, handleTableSchema},
+		{"/{table}/{key}/distinct", http.MethodGet, nil, handleDistinct},
+		{"/{table}/{key}", http.MethodGet, append([]string{"select", "embed"}, format...), handleGetRecord},
+		{"/{table}/{key}", http.MethodPost, []string{}, handleUpdateRecord},
+		{"/{table}/{key}", http.MethodDelete, []string{}, handleDeleteRecord},
+	}
+}
+
+// ServeHTTP answers with the schema loaded at the time of the request,
+// a reload in the meantime does not change the schema the request sees
+func (d *DbExplorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.withSchema(d.currentSchema.Load()).serve(w, r)
+}
+
+// withSchema copies the explorer for a request working with the given schema
+func (d *DbExplorer) withSchema(schema *Schema) *DbExplorer {
+	request := *d
+	request.schema = schema
+	return &request
+}
+
+func (d *DbExplorer) serve(w http.ResponseWriter, r *http.Request) {
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		writeError(w, "invalid query string", http.StatusBadRequest)
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	segments := strings.Split(path[1:], "/")
+
+	pattern := ""
+	context := RouteContext{Query: query}
+	matched := make([]Route, 0)
+	for _, route := range d.Routes {
+		if pattern != "" && route.Pattern != pattern {
+			continue
+		}
+		routeContext, ok, err := matchRoute(route.Pattern, segments)
+		if err != nil {
+			writeError(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		if ok {
+			pattern = route.Pattern
+			routeContext.Query = query
+			context = routeContext
+			matched = append(matched, route)
+		}
+	}
+	if len(matched) == 0 {
+		writeError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if strings.Contains(pattern, "{table}") && !slices.Contains(d.schema.TableNames, context.Table) {
+		writeError(w, "unknown table", http.StatusNotFound)
+		return
+	}
+
+	allowed := make([]string, 0, len(matched)+2)
+	for _, route := range matched {
+		allowed = append(allowed, route.Method)
+		if route.Method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	allowed = append(allowed, http.MethodOptions)
+
+	method := r.Method
+	if method == http.MethodHead {
+		// the server drops the body of responses to HEAD requests
+		method = http.MethodGet
+	}
+	if method == http.MethodOptions {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	index := slices.IndexFunc(matched, func(route Route) bool { return route.Method == method })
+	if index == -1 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	route := matched[index]
+	if route.Params != nil {
+		for name := range query {
+			if !slices.Contains(route.Params, name) {
+				writeError(w, fmt.Sprintf("unknown parameter %s", name), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	err = route.Handle(d, w, r, context)
+	if err != nil {
+		writeFailure(w, err, context.Table, d)
+	}
+}
+
+func matchRoute(pattern string, segments []string) (RouteContext, bool, error) {
+	context := RouteContext{}
+	parts := strings.Split(pattern[1:], "/")
+	if len(parts) != len(segments) {
+		return context, false, nil
+	}
+
+	for i, part := range parts {
+		switch part {
+		case "{table}":
+			table, err := url.PathUnescape(segments[i])
+			if err != nil {
+				return context, false, err
+			}
+			context.Table = table
+		case "{key}":
+			context.Key = segments[i]
+		default:
+			if part != segments[i] {
+				return context, false, nil
+			}
+			continue
+		}
+		if segments[i] == "" {
+			return context, false, nil
+		}
+	}
+	return context, true, nil
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	return io.ReadAll(r.Body)
+}
+
+func handleTables(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	writeTables(w, d.schema.TableNames)
+	return nil
+}
+
+func handleBatch(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	format, err := parseFormat(c.Query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	return runBatch(d, w, body, format)
+}
+
+func handleList(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	params, err := parseListParams(c.Table, d, c.Query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if params.Cursor {
+		return getWithCursor(c.Table, params, d, w)
+	}
+	if params.Paged {
+		return getWithLimitAndOffset(c.Table, params, d, w)
+	}
+	return getRows(c.Table, d, w, params)
+}
+
+func handleCreate(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	format, err := parseFormat(c.Query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if onConflict := c.Query.Get("on_conflict"); onConflict != "" {
+		return upsertRow(c.Table, d, w, body, onConflict, format)
+	}
+	return createRow(c.Table, d, w, body, format)
+}
+
+func handleUpdateRows(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	return updateRows(c.Table, d, w, body, c.Query)
+}
+
+func handleDeleteRows(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	return deleteRows(c.Table, d, w, c.Query)
+}
+
+func handleSearch(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	return searchRows(c.Table, d, w, c.Query)
+}
+
+func handleAggregate(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	return aggregateRows(c.Table, d, w, c.Query)
+}
+
+func handleDistinct(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	column, err := url.PathUnescape(c.Key)
+	if err != nil {
+		writeError(w, "invalid path", http.StatusBadRequest)
+		return nil
+	}
+	return distinctValues(c.Table, column, d, w, c.Query)
+}
+
+func handleGetRecord(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	params, err := parseRecordParams(c.Table, d, c.Query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	return getById(c.Table, d, w, "/"+c.Key, params)
+}
+
+func handleUpdateRecord(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	return updateRow(c.Table, d, w, body, "/"+c.Key, r.Header.Get("If-Match"))
+}
+
+func handleDeleteRecord(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	return deleteRow(c.Table, d, w, "/"+c.Key, r.Header.Get("If-Match"))
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Schema is the model of the database read by loadSchema,
+// it is replaced as a whole when the schema is reloaded
+type Schema struct {
+	TableNames  []string
+	Data        map[string][]FieldMetaData
+	PrimaryKeys map[string][]FieldMetaData
+	ForeignKeys map[string][]ForeignKey
+	Indexes     map[string][]Index
+}
+
+type ColumnSchema struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Nullable  bool    `json:"nullable"`
+	Default   *string `json:"default"`
+	Key       string  `json:"key"`
+	Extra     string  `json:"extra"`
+	Comment   string  `json:"comment"`
+	Collation *string `json:"collation"`
+}
+
+type TableSchema struct {
+	Name        string         `json:"name"`
+	Columns     []ColumnSchema `json:"columns"`
+	PrimaryKey  []string       `json:"primary_key"`
+	Indexes     []Index        `json:"indexes"`
+	ForeignKeys []ForeignKey   `json:"foreign_keys"`
+}
+
+func describeTable(table string, d *DbExplorer) TableSchema {
+	columns := make([]ColumnSchema, len(d.schema.Data[table]))
+	for i, datum := range d.schema.Data[table] {
+		columns[i] = ColumnSchema{
+			Name:      datum.Field,
+			Type:      datum.Type,
+			Nullable:  datum.Null.String == "YES",
+			Default:   nullableString(datum.Default),
+			Key:       datum.Key.String,
+			Extra:     datum.Extra.String,
+			Comment:   datum.Comment.String,
+			Collation: nullableString(datum.Collation),
+		}
+	}
+
+	indexes := d.schema.Indexes[table]
+	if indexes == nil {
+		indexes = make([]Index, 0)
+	}
+	foreignKeys := d.schema.ForeignKeys[table]
+	if foreignKeys == nil {
+		foreignKeys = make([]ForeignKey, 0)
+	}
+	return TableSchema{
+		Name:        table,
+		Columns:     columns,
+		PrimaryKey:  extractFieldNames(getPrimaryKey(table, d)),
+		Indexes:     indexes,
+		ForeignKeys: foreignKeys,
+	}
+}
+
+func nullableString(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func handleSchema(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	tables := make([]TableSchema, len(d.schema.TableNames))
+	for i, table := range d.schema.TableNames {
+		tables[i] = describeTable(table, d)
+	}
+	writeResponse(w, struct {
+		Tables []TableSchema `json:"tables"`
+	}{tables})
+	return nil
+}
+
+func handleTableSchema(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	writeResponse(w, struct {
+		Table TableSchema `json:"table"`
+	}{describeTable(c.Table, d)})
+	return nil
+}
+
+// Schema returns the schema the following requests are served with
+func (d *DbExplorer) Schema() *Schema {
+	return d.currentSchema.Load()
+}
+
+// ReloadSchema reads the schema again and swaps it for the following requests,
+// the returned changes are logged as well. Reloads run one at a time, so an
+// older snapshot never replaces a newer one.
+func (d *DbExplorer) ReloadSchema() ([]string, error) {
+	d.reloadMutex.Lock()
+	defer d.reloadMutex.Unlock()
+
+	schema, err := loadSchema(d.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	old := d.currentSchema.Swap(schema)
+	changes := diffSchemas(old.Data, schema.Data)
+
+	for _, change := range changes {
+		log.Printf("schema reload: %s", change)
+	}
+	return changes, nil
+}
+
+// PollSchema reloads the schema every interval until stop is called,
+// stop returns once a running reload has finished
+func (d *DbExplorer) PollSchema(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := d.ReloadSchema(); err != nil {
+					log.Printf("schema reload failed: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func diffSchemas(old map[string][]FieldMetaData, current map[string][]FieldMetaData) []string {
+	changes := make([]string, 0)
+	for _, table := range extractTableNames(old) {
+		if _, exists := current[table]; !exists {
+			changes = append(changes, fmt.Sprintf("table %s removed", table))
+		}
+	}
+	for _, table := range extractTableNames(current) {
+		oldFields, exists := old[table]
+		if !exists {
+			changes = append(changes, fmt.Sprintf("table %s added", table))
+			continue
+		}
+
+		for _, datum := range oldFields {
+			if findField(current[table], datum.Field) == nil {
+				changes = append(changes, fmt.Sprintf("column %s.%s removed", table, datum.Field))
+			}
+		}
+		for _, datum := range current[table] {
+			oldDatum := findField(oldFields, datum.Field)
+			if oldDatum == nil {
+				changes = append(changes, fmt.Sprintf("column %s.%s added", table, datum.Field))
+			} else if *oldDatum != datum {
+				changes = append(changes, fmt.Sprintf("column %s.%s changed", table, datum.Field))
+			}
+		}
+	}
+	return changes
+}
+
+func handleReloadSchema(d *DbExplorer, w http.ResponseWriter, r *http.Request, c RouteContext) error {
+	changes, err := d.ReloadSchema()
+	if err != nil {
+		return err
+	}
+	writeResponse(w, struct {
+		Changes []string `json:"changes"`
+	}{changes})
+	return nil
+}
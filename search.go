@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Type    string   `json:"type"`
+	// Functional indexes have key parts on expressions, which Columns leaves out
+	Functional bool `json:"-"`
+}
+
+func loadIndexes(db *sql.DB) (map[string][]Index, error) {
+	rs, err := db.Query("SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME, INDEX_TYPE " +
+		"FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() " +
+		"ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	indexes := make(map[string][]Index)
+	for rs.Next() {
+		var table, name, indexType string
+		var column sql.NullString
+		var nonUnique int
+		if err := rs.Scan(&table, &name, &nonUnique, &column, &indexType); err != nil {
+			return nil, err
+		}
+
+		tableIndexes := indexes[table]
+		if len(tableIndexes) == 0 || tableIndexes[len(tableIndexes)-1].Name != name {
+			tableIndexes = append(tableIndexes, Index{Name: name, Unique: nonUnique == 0, Type: indexType})
+		}
+		last := &tableIndexes[len(tableIndexes)-1]
+		if column.Valid {
+			last.Columns = append(last.Columns, column.String)
+		} else {
+			last.Functional = true
+		}
+		indexes[table] = tableIndexes
+	}
+	return indexes, rs.Err()
+}
+
+func searchRows(table string, d *DbExplorer, w http.ResponseWriter, query url.Values) error {
+	for name := range query {
+		switch name {
+		case "q", "select", "limit", "offset", "decimals", "bigints":
+		default:
+			writeError(w, fmt.Sprintf("unknown parameter %s", name), http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	search := query.Get("q")
+	if search == "" {
+		writeError(w, "q is required", http.StatusBadRequest)
+		return nil
+	}
+	fields, err := parseSelect(d.schema.Data[table], query.Get("select"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	limit, err := parseLimitOrOffset(query, "limit", 5)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	offset, err := parseLimitOrOffset(query, "offset", 0)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	score, condition, termArgs := buildSearchScore(table, d, search)
+	if score == "" {
+		writeError(w, fmt.Sprintf("table %s has no text fields", table), http.StatusBadRequest)
+		return nil
+	}
+	// MATCH in WHERE lets the server use the FULLTEXT index
+	args := append(append(termArgs, termArgs...), limit, offset)
+
+	scoreField := FieldMetaData{Field: "_score", Type: "float"}
+	order := buildOrderClause(withPrimaryKeyOrder([]OrderBy{{Field: scoreField, Descending: true}}, getPrimaryKey(table, d)))
+	fullQuery := fmt.Sprintf(d.SearchQuery, getAndFormatFieldNamesForQuery(fields), score, escapeIdentifier(table), condition, order)
+	rs, err := d.DB.Query(fullQuery, args...)
+	if err != nil {
+		return err
+	}
+	result, err := processRs(rs, append(withFields(fields), scoreField))
+	rs.Close()
+	if err != nil {
+		return err
+	}
+
+	format.apply(result)
+	writeRecords(w, result, nil)
+	return nil
+}
+
+// text fields covered by a FULLTEXT index are matched through it and give a real
+// relevance, the others add one to the score when they contain the search string.
+// The terms are joined into the score and into the condition, which repeats the args.
+func buildSearchScore(table string, d *DbExplorer, search string) (string, string, []interface{}) {
+	terms := make([]string, 0)
+	args := make([]interface{}, 0)
+	covered := make(map[string]bool)
+	for _, index := range d.schema.Indexes[table] {
+		if index.Type != "FULLTEXT" {
+			continue
+		}
+		terms = append(terms, fmt.Sprintf("MATCH (`%s`) AGAINST (? IN NATURAL LANGUAGE MODE)", escapeIdentifiers(index.Columns)))
+		args = append(args, search)
+		for _, column := range index.Columns {
+			covered[column] = true
+		}
+	}
+
+	pattern := "%" + escapeLike(search) + "%"
+	for _, datum := range d.schema.Data[table] {
+		if isTextType(datum.Type) && !datum.JSON && !covered[datum.Field] {
+			terms = append(terms, fmt.Sprintf("(`%s` LIKE ?)", escapeIdentifier(datum.Field)))
+			args = append(args, pattern)
+		}
+	}
+	return strings.Join(terms, " + "), strings.Join(terms, " OR "), args
+}
+
+func isTextType(valueType string) bool {
+	return strings.Contains(valueType, "char") || strings.Contains(valueType, "text")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
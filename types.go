@@ -0,0 +1,339 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Decimal keeps DECIMAL values exact, it is written as a JSON string
+// unless numbers are requested with decimals=number
+type Decimal string
+
+// BigInt and BigUint mark values read from BIGINT columns, bigints=string
+// only touches them, not counts or BIT values
+type BigInt int64
+
+type BigUint uint64
+
+type Format struct {
+	DecimalsAsNumbers bool
+	BigIntsAsStrings  bool
+}
+
+func parseFormat(query url.Values) (Format, error) {
+	format := Format{}
+	switch query.Get("decimals") {
+	case "", "string":
+	case "number":
+		format.DecimalsAsNumbers = true
+	default:
+		return format, fmt.Errorf("invalid decimals %s", query.Get("decimals"))
+	}
+	switch query.Get("bigints") {
+	case "", "number":
+	case "string":
+		format.BigIntsAsStrings = true
+	default:
+		return format, fmt.Errorf("invalid bigints %s", query.Get("bigints"))
+	}
+	return format, nil
+}
+
+func (f Format) apply(records []map[string]interface{}) {
+	for _, record := range records {
+		for key, value := range record {
+			switch typed := value.(type) {
+			case Decimal:
+				if f.DecimalsAsNumbers {
+					record[key] = json.Number(typed)
+				}
+			case BigInt:
+				if f.BigIntsAsStrings {
+					record[key] = strconv.FormatInt(int64(typed), 10)
+				}
+			case BigUint:
+				if f.BigIntsAsStrings {
+					record[key] = strconv.FormatUint(uint64(typed), 10)
+				}
+			case map[string]interface{}:
+				f.apply([]map[string]interface{}{typed})
+			case []map[string]interface{}:
+				f.apply(typed)
+			}
+		}
+	}
+}
+
+func baseType(valueType string) string {
+	name, _, _ := strings.Cut(valueType, "(")
+	name, _, _ = strings.Cut(name, " ")
+	return strings.ToLower(name)
+}
+
+func typeParams(valueType string) []string {
+	_, rest, found := strings.Cut(valueType, "(")
+	if !found {
+		return nil
+	}
+	end := strings.LastIndex(rest, ")")
+	if end == -1 {
+		return nil
+	}
+	return strings.Split(rest[:end], ",")
+}
+
+func isUnsigned(valueType string) bool {
+	return strings.Contains(strings.ToLower(valueType), "unsigned")
+}
+
+func typeKind(valueType string) string {
+	params := typeParams(valueType)
+	switch baseType(valueType) {
+	case "tinyint":
+		if len(params) == 1 && params[0] == "1" {
+			return "bool"
+		}
+		fallthrough
+	case "smallint", "mediumint", "int", "integer", "bigint":
+		if isUnsigned(valueType) {
+			return "uint"
+		}
+		return "int"
+	case "year":
+		return "int"
+	case "float", "double", "real":
+		return "float"
+	case "decimal", "numeric":
+		return "decimal"
+	case "datetime", "timestamp":
+		return "datetime"
+	case "date":
+		return "date"
+	case "time":
+		return "time"
+	case "json":
+		return "json"
+	case "bit":
+		if len(params) == 0 || params[0] == "1" {
+			return "bool"
+		}
+		return "bit"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return "binary"
+	}
+	return "string"
+}
+
+// loadJSONColumns sets JSON on the columns MariaDB reports as longtext
+// with a json_valid check. MySQL reports json itself and its CHECK_CONSTRAINTS
+// has no TABLE_NAME or is missing before 8.0.16, so errors for those are ignored.
+func loadJSONColumns(db *sql.DB, tablesData map[string][]FieldMetaData) error {
+	rs, err := db.Query("SELECT TABLE_NAME, CHECK_CLAUSE FROM information_schema.CHECK_CONSTRAINTS " +
+		"WHERE CONSTRAINT_SCHEMA = DATABASE()")
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) && (mysqlError.Number == 1054 || mysqlError.Number == 1109) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	for rs.Next() {
+		var table, clause string
+		if err := rs.Scan(&table, &clause); err != nil {
+			return err
+		}
+		column, found := jsonValidColumn(clause)
+		if !found {
+			continue
+		}
+		for i, datum := range tablesData[table] {
+			if datum.Field == column && typeKind(datum.Type) == "string" {
+				tablesData[table][i].JSON = true
+			}
+		}
+	}
+	return rs.Err()
+}
+
+// jsonValidColumn takes the column out of a json_valid(`column`) check clause
+func jsonValidColumn(clause string) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(clause), "json_valid(`") || !strings.HasSuffix(clause, "`)") {
+		return "", false
+	}
+	column := clause[len("json_valid(`") : len(clause)-len("`)")]
+	return strings.ReplaceAll(column, "``", "`"), true
+}
+
+// fieldKind is the typeKind of a field, json for the columns marked by loadJSONColumns
+func fieldKind(datum FieldMetaData) string {
+	if datum.JSON {
+		return "json"
+	}
+	return typeKind(datum.Type)
+}
+
+func isIntegerType(valueType string) bool {
+	kind := typeKind(valueType)
+	return kind == "int" || kind == "uint"
+}
+
+func isFloatType(valueType string) bool {
+	kind := typeKind(valueType)
+	return kind == "float" || kind == "decimal"
+}
+
+func integerBits(valueType string) uint {
+	switch baseType(valueType) {
+	case "tinyint":
+		return 8
+	case "smallint", "year":
+		return 16
+	case "mediumint":
+		return 24
+	case "bigint":
+		return 64
+	case "bit":
+		params := typeParams(valueType)
+		if len(params) == 1 {
+			bits, _ := strconv.Atoi(params[0])
+			return uint(bits)
+		}
+		return 1
+	default:
+		return 32
+	}
+}
+
+// enum('a','b') and set('a','b') members, quotes inside values are doubled
+func enumValues(valueType string) []string {
+	_, rest, found := strings.Cut(valueType, "(")
+	if !found {
+		return nil
+	}
+
+	values := make([]string, 0)
+	var current strings.Builder
+	quoted := false
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(rest) && rest[i+1] == '\'':
+			current.WriteByte('\'')
+			i++
+		case c == '\'':
+			quoted = !quoted
+			if !quoted {
+				values = append(values, current.String())
+				current.Reset()
+			}
+		case quoted:
+			current.WriteByte(c)
+		}
+	}
+	return values
+}
+
+// checkTimeZone refuses sessions that are not in UTC. Datetime values are read and
+// written as UTC and the server converts TIMESTAMP columns to the session time zone,
+// so any other zone would shift them by its offset.
+func checkTimeZone(db *sql.DB) error {
+	var offset int64
+	err := db.QueryRow("SELECT TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), NOW())").Scan(&offset)
+	if err != nil {
+		return err
+	}
+	if offset != 0 {
+		return fmt.Errorf("session time zone is %+d seconds off UTC, set time_zone='+00:00' in the DSN", offset)
+	}
+	return nil
+}
+
+func parseDateTime(value string) (time.Time, error) {
+	layouts := []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "2006-01-02"}
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid datetime %s", value)
+}
+
+// convertQueryValue converts a value taken from the url, a path or a cursor
+// into something the driver can bind for the given field
+func convertQueryValue(value string, datum FieldMetaData) (interface{}, error) {
+	switch fieldKind(datum) {
+	case "bool":
+		return strconv.ParseBool(value)
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "uint", "bit":
+		return strconv.ParseUint(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "decimal":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case "datetime":
+		parsed, err := parseDateTime(value)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Format("2006-01-02 15:04:05.999999"), nil
+	case "binary":
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return value, nil
+}
+
+// convertInput converts a validated value from a request body into
+// something the driver can bind for the given field
+func convertInput(value interface{}, datum FieldMetaData) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldKind(datum) {
+	case "json":
+		marshal, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(marshal), nil
+	case "datetime", "binary":
+		if text, ok := value.(string); ok {
+			return convertQueryValue(text, datum)
+		}
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return value, nil
+	}
+	switch fieldKind(datum) {
+	case "bool", "int", "uint", "bit":
+		integer, ok := parseInteger(number)
+		if !ok {
+			return nil, fmt.Errorf("field %s have invalid type", datum.Field)
+		}
+		if integer.IsInt64() {
+			return integer.Int64(), nil
+		}
+		return integer.Uint64(), nil
+	case "float":
+		return number.Float64()
+	}
+	return number.String(), nil
+}
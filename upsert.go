@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// upsertRow inserts the record or updates the row that has the same values
+// in the on_conflict columns. MySQL takes any unique key into account,
+// so a record colliding with another row on a different key is refused.
+func upsertRow(table string, d *DbExplorer, w http.ResponseWriter, body []byte, onConflict string, format Format) error {
+	conflict, err := parseConflictTarget(d.schema.Data[table], d.schema.Indexes[table], onConflict)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		writeError(w, "on_conflict can not be used with a list of records", http.StatusBadRequest)
+		return nil
+	}
+
+	input := make(map[string]interface{})
+	err = decodeBody(body, &input)
+	if err != nil {
+		return err
+	}
+
+	fieldErrors := append(validateInput(d.schema.Data[table], input, false), versionFieldErrors(table, d, input)...)
+	for _, datum := range conflict {
+		value := input[datum.Field]
+		if value == nil {
+			reported := slices.ContainsFunc(fieldErrors, func(fieldError FieldError) bool { return fieldError.Field == datum.Field })
+			if !reported {
+				fieldErrors = append(fieldErrors, FieldError{Field: datum.Field, Message: "missing value"})
+			}
+			continue
+		}
+		// auto increment fields are skipped by validateInput on insert
+		if datum.Extra.String != "auto_increment" {
+			continue
+		}
+		if message := validateValue(datum, value); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: datum.Field, Message: message})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return nil
+	}
+	// only the sent fields are updated, the filled ones are for new rows
+	sent := make(map[string]bool, len(input))
+	for name := range input {
+		sent[name] = true
+	}
+	fillMissingFields(d.schema.Data[table], input)
+
+	fieldNames, fieldValues, err := createDataForQuery(d.schema.Data[table], input)
+	if err != nil {
+		return err
+	}
+	conflictValues := make([]interface{}, len(conflict))
+	for i, datum := range conflict {
+		conflictValues[i], err = convertInput(input[datum.Field], datum)
+		if err != nil {
+			return err
+		}
+		if datum.Extra.String == "auto_increment" {
+			fieldNames = append(fieldNames, datum.Field)
+			fieldValues = append(fieldValues, conflictValues[i])
+		}
+	}
+
+	// the primary key of an existing row is kept, like updateRow does
+	primaryKey := getPrimaryKey(table, d)
+	assignments := make([]string, 0, len(fieldNames))
+	for _, name := range fieldNames {
+		if sent[name] && findField(conflict, name) == nil && findField(primaryKey, name) == nil {
+			column := escapeIdentifier(name)
+			assignments = append(assignments, fmt.Sprintf("`%s` = VALUES(`%s`)", column, column))
+		}
+	}
+	update := withVersionBump(table, d, strings.Join(assignments, ", "))
+	if len(assignments) == 0 {
+		// only the key is sent, an existing row is left as it is
+		column := escapeIdentifier(conflict[0].Field)
+		update = fmt.Sprintf("`%s` = `%s`", column, column)
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	values := make(map[string]interface{}, len(fieldNames))
+	for i, name := range fieldNames {
+		values[name] = fieldValues[i]
+	}
+	err = checkOtherConflicts(tx, table, d, conflict, values)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(d.UpsertQuery,
+		escapeIdentifier(table),
+		escapeIdentifiers(fieldNames),
+		strings.TrimSuffix(strings.Repeat("?, ", len(fieldValues)), ", "),
+		update)
+	_, err = tx.Exec(query, fieldValues...)
+	if err != nil {
+		return err
+	}
+
+	rs, err := tx.Query(fmt.Sprintf(d.GetByIdQuery,
+		getAndFormatFieldNamesForQuery(primaryKey),
+		escapeIdentifier(table),
+		buildPrimaryKeyCondition(conflict)), conflictValues...)
+	if err != nil {
+		return err
+	}
+	result, err := processRs(rs, primaryKey)
+	rs.Close()
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return fmt.Errorf("no id returned for upserted row")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	format.apply(result)
+	writeResponse(w, result[0])
+	return nil
+}
+
+// the conflict target has to be exactly the columns of a unique index,
+// otherwise MySQL would not detect the duplicate
+func parseConflictTarget(tableData []FieldMetaData, indexes []Index, onConflict string) ([]FieldMetaData, error) {
+	names := strings.Split(onConflict, ",")
+	conflict := make([]FieldMetaData, 0, len(names))
+	for _, name := range names {
+		datum := findField(tableData, name)
+		if datum == nil {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+		if findField(conflict, name) == nil {
+			conflict = append(conflict, *datum)
+		}
+	}
+
+	for _, index := range indexes {
+		if index.Unique && !index.Functional && coversColumns(index, conflict) {
+			return conflict, nil
+		}
+	}
+	return nil, fmt.Errorf("no unique index on %s", onConflict)
+}
+
+func coversColumns(index Index, fields []FieldMetaData) bool {
+	if len(index.Columns) != len(fields) {
+		return false
+	}
+	for _, datum := range fields {
+		if !slices.Contains(index.Columns, datum.Field) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkOtherConflicts refuses a record whose values in another unique index
+// belong to a row other than the on_conflict target, ON DUPLICATE KEY UPDATE
+// would update that row instead. The rows found stay locked until the end of the
+// transaction, indexes with a NULL or a missing value can not collide.
+// Functional indexes are left to MySQL, their key parts can not be compared here.
+func checkOtherConflicts(q Querier, table string, d *DbExplorer, conflict []FieldMetaData, values map[string]interface{}) error {
+	primaryKey := getPrimaryKey(table, d)
+	target, err := lockByColumns(q, table, d, conflict, values)
+	if err != nil {
+		return err
+	}
+	targetKey, _ := relationKey(target, extractFieldNames(primaryKey))
+
+	for _, index := range d.schema.Indexes[table] {
+		if !index.Unique || index.Functional || coversColumns(index, conflict) {
+			continue
+		}
+		columns := make([]FieldMetaData, 0, len(index.Columns))
+		for _, name := range index.Columns {
+			if values[name] == nil {
+				break
+			}
+			columns = append(columns, *findField(d.schema.Data[table], name))
+		}
+		if len(columns) != len(index.Columns) {
+			continue
+		}
+
+		row, err := lockByColumns(q, table, d, columns, values)
+		if err != nil {
+			return err
+		}
+		if key, found := relationKey(row, extractFieldNames(primaryKey)); found && (target == nil || key != targetKey) {
+			return &APIError{Status: http.StatusConflict, Code: "duplicate_entry", Message: "duplicate entry",
+				Field:  indexField(d.schema.Indexes[table], index.Name),
+				Detail: fmt.Sprintf("unique key %s matches a row other than the on_conflict target", index.Name)}
+		}
+	}
+	return nil
+}
+
+// lockByColumns reads the primary key of the row with the given values, nil when there is none
+func lockByColumns(q Querier, table string, d *DbExplorer, columns []FieldMetaData, values map[string]interface{}) (map[string]interface{}, error) {
+	primaryKey := getPrimaryKey(table, d)
+	args := make([]interface{}, len(columns))
+	for i, datum := range columns {
+		args[i] = values[datum.Field]
+	}
+	query := fmt.Sprintf(d.GetByIdQuery,
+		getAndFormatFieldNamesForQuery(primaryKey),
+		escapeIdentifier(table),
+		buildPrimaryKeyCondition(columns)) + " FOR UPDATE"
+	rs, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := processRs(rs, primaryKey)
+	rs.Close()
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
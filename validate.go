@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes an invalid field of a request body
+type FieldError struct {
+	// Row is the index of the record in a bulk insert
+	Row     *int   `json:"row,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	if e.Row != nil {
+		return fmt.Sprintf("row %d field %s have %s", *e.Row, e.Field, e.Message)
+	}
+	return fmt.Sprintf("field %s have %s", e.Field, e.Message)
+}
+
+func joinFieldErrors(fieldErrors []FieldError) string {
+	messages := make([]string, len(fieldErrors))
+	for i, fieldError := range fieldErrors {
+		messages[i] = fieldError.Error()
+	}
+	return strings.Join(messages, ", ")
+}
+
+func validateInput(tableData []FieldMetaData, input map[string]interface{}, update bool) []FieldError {
+	fieldErrors := make([]FieldError, 0)
+	for _, datum := range tableData {
+		value, exists := input[datum.Field]
+		if !exists {
+			if _, filled := zeroValue(datum); !update && requiresValue(datum) && !filled {
+				fieldErrors = append(fieldErrors, FieldError{Field: datum.Field, Message: "missing value"})
+			}
+			continue
+		}
+		// primary key can not be changed, on insert auto increment keys are ignored
+		if update && datum.Key.String == "PRI" {
+			fieldErrors = append(fieldErrors, FieldError{Field: datum.Field, Message: "invalid type"})
+			continue
+		}
+		if !update && datum.Extra.String == "auto_increment" {
+			continue
+		}
+		if message := validateValue(datum, value); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: datum.Field, Message: message})
+		}
+	}
+	return fieldErrors
+}
+
+// validateUpdate is validateInput for updates of the table, including its version column
+func validateUpdate(table string, d *DbExplorer, input map[string]interface{}) []FieldError {
+	return append(validateInput(d.schema.Data[table], input, true), versionFieldErrors(table, d, input)...)
+}
+
+func validateValue(datum FieldMetaData, value interface{}) string {
+	if value == nil {
+		if datum.Null.String == "NO" {
+			return "invalid type"
+		}
+		return ""
+	}
+
+	switch fieldKind(datum) {
+	case "bool":
+		if _, ok := value.(bool); ok {
+			return ""
+		}
+		return validateInteger(datum, value)
+	case "int", "uint", "bit":
+		return validateInteger(datum, value)
+	case "float":
+		number, ok := value.(json.Number)
+		if !ok {
+			return "invalid type"
+		}
+		parsed, err := number.Float64()
+		if err != nil || (isUnsigned(datum.Type) && parsed < 0) {
+			return "out of range value"
+		}
+	case "decimal":
+		var text string
+		switch typed := value.(type) {
+		case json.Number:
+			text = typed.String()
+		case string:
+			text = typed
+		default:
+			return "invalid type"
+		}
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return "invalid type"
+		}
+		if isUnsigned(datum.Type) && number < 0 {
+			return "out of range value"
+		}
+		if params := typeParams(datum.Type); len(params) == 2 {
+			precision, _ := strconv.Atoi(strings.TrimSpace(params[0]))
+			scale, _ := strconv.Atoi(strings.TrimSpace(params[1]))
+			if math.Abs(number) >= math.Pow10(precision-scale) {
+				return "out of range value"
+			}
+		}
+	case "json":
+	case "datetime", "date":
+		text, ok := value.(string)
+		if !ok {
+			return "invalid type"
+		}
+		if _, err := parseDateTime(text); err != nil {
+			return "invalid value"
+		}
+	case "binary":
+		text, ok := value.(string)
+		if !ok {
+			return "invalid type"
+		}
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return "invalid value"
+		}
+		if params := typeParams(datum.Type); len(params) == 1 {
+			length, _ := strconv.Atoi(params[0])
+			if len(decoded) > length {
+				return "too long value"
+			}
+		}
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return "invalid type"
+		}
+		switch baseType(datum.Type) {
+		case "char", "varchar":
+			params := typeParams(datum.Type)
+			if len(params) == 1 {
+				length, _ := strconv.Atoi(params[0])
+				if utf8.RuneCountInString(text) > length {
+					return "too long value"
+				}
+			}
+		case "enum":
+			if !slices.Contains(enumValues(datum.Type), text) {
+				return "invalid value"
+			}
+		case "set":
+			members := enumValues(datum.Type)
+			for _, member := range strings.Split(text, ",") {
+				if text != "" && !slices.Contains(members, member) {
+					return "invalid value"
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func validateInteger(datum FieldMetaData, value interface{}) string {
+	number, ok := value.(json.Number)
+	if !ok {
+		return "invalid type"
+	}
+	integer, ok := parseInteger(number)
+	if !ok {
+		return "invalid type"
+	}
+	min, max := integerRange(datum.Type)
+	if integer.Cmp(min) < 0 || integer.Cmp(max) > 0 {
+		return "out of range value"
+	}
+	return ""
+}
+
+// parseInteger keeps all digits of the number, so bigint values
+// above 2^53 are not rounded like they would be as float64
+func parseInteger(number json.Number) (*big.Int, bool) {
+	if integer, ok := new(big.Int).SetString(number.String(), 10); ok {
+		return integer, true
+	}
+	parsed, _, err := big.ParseFloat(number.String(), 10, 256, big.ToNearestEven)
+	if err != nil || !parsed.IsInt() {
+		return nil, false
+	}
+	integer, _ := parsed.Int(nil)
+	return integer, true
+}
+
+func integerRange(valueType string) (*big.Int, *big.Int) {
+	bits := integerBits(valueType)
+	one := big.NewInt(1)
+	if isUnsigned(valueType) || baseType(valueType) == "bit" {
+		return big.NewInt(0), new(big.Int).Sub(new(big.Int).Lsh(one, bits), one)
+	}
+	limit := new(big.Int).Lsh(one, bits-1)
+	return new(big.Int).Neg(limit), new(big.Int).Sub(limit, one)
+}
+
+// strict mode rejects inserts that miss NOT NULL fields without a default,
+// they get the zero value of their type instead
+func fillMissingFields(tableData []FieldMetaData, input map[string]interface{}) {
+	for _, datum := range tableData {
+		if _, exists := input[datum.Field]; exists || !requiresValue(datum) {
+			continue
+		}
+		if value, ok := zeroValue(datum); ok {
+			input[datum.Field] = value
+		}
+	}
+}
+
+func requiresValue(datum FieldMetaData) bool {
+	return datum.Null.String == "NO" && !datum.Default.Valid &&
+		datum.Extra.String != "auto_increment" && !strings.Contains(datum.Extra.String, "GENERATED")
+}
+
+// zeroValue has no value for dates, times, json and binary fields,
+// validateInput reports them as missing instead
+func zeroValue(datum FieldMetaData) (interface{}, bool) {
+	switch kind := fieldKind(datum); {
+	case kind == "json":
+	case kind == "bool", isIntegerType(datum.Type), isFloatType(datum.Type), kind == "bit":
+		return json.Number("0"), true
+	case baseType(datum.Type) == "enum":
+		if members := enumValues(datum.Type); len(members) > 0 {
+			return members[0], true
+		}
+	case isTextType(datum.Type), baseType(datum.Type) == "set":
+		return "", true
+	}
+	return nil, false
+}